@@ -0,0 +1,352 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// fetchEvents downloads a user's public events feed. GitHub only keeps about
+// 90 days / 300 events here, which is why every fetch is also recorded.
+func fetchEvents(username string) ([]GithubEvent, error) {
+	if os.Getenv("GITHUB_TOKEN") == "" {
+		return nil, fmt.Errorf("GITHUB_TOKEN environment variable not set")
+	}
+	var events []GithubEvent
+	err := githubJSON("GET", fmt.Sprintf("%s/users/%s/events/public", githubAPI, username), nil, &events)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// fetchActivity fetches a user's events and stores a snapshot of the result,
+// including failed fetches so gaps in the history are visible.
+func fetchActivity(username string) error {
+	events, err := fetchEvents(username)
+	if recErr := recordSnapshot(username, events, err); recErr != nil {
+		return recErr
+	}
+	return err
+}
+
+// activityMaxAge is how old a stored snapshot may be before the TUI fetches
+// from GitHub again. With the sync daemon running, views read from the
+// database and open instantly.
+const activityMaxAge = 30 * time.Minute
+
+// refreshActivity fetches a user's activity unless a successful snapshot
+// newer than activityMaxAge is already stored.
+func refreshActivity(username string) error {
+	var fetchedAt time.Time
+	err := db.QueryRow(`
+		SELECT fetched_at FROM activity_snapshots
+		WHERE username = ? AND error IS NULL
+		ORDER BY fetched_at DESC LIMIT 1`,
+		username).Scan(&fetchedAt)
+	if err == nil && time.Since(fetchedAt) < activityMaxAge {
+		return nil
+	}
+	return fetchActivity(username)
+}
+
+// recordSnapshot stores one activity fetch. Push events are kept in
+// activity_pushes (deduplicated across fetches) so history outlives the
+// GitHub feed; the snapshot row records what the fetch looked like.
+func recordSnapshot(username string, events []GithubEvent, fetchErr error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lastPush *time.Time
+	pushes, commits := 0, 0
+	for _, event := range events {
+		if event.Type != "PushEvent" {
+			continue
+		}
+		pushedAt := event.CreatedAt.UTC()
+		if lastPush == nil || pushedAt.After(*lastPush) {
+			lastPush = &pushedAt
+		}
+		pushes++
+		commits += event.Payload.Size
+
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO activity_pushes (username, repo, pushed_at, commits)
+			VALUES (?, ?, ?, ?)`,
+			username, event.Repo.Name, pushedAt, event.Payload.Size)
+		if err != nil {
+			return err
+		}
+	}
+
+	var errText *string
+	if fetchErr != nil {
+		msg := fetchErr.Error()
+		errText = &msg
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO activity_snapshots (username, fetched_at, last_push, push_count, commit_count, local_head, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		username, time.Now().UTC(), lastPush, pushes, commits, localHead(username), errText)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// localHead returns the commit checked out in the student's local clone,
+// or an empty string when there is no clone.
+func localHead(username string) string {
+	if !isCloned(username) {
+		return ""
+	}
+	head, err := vcs.Head(username)
+	if err != nil {
+		return ""
+	}
+	return head
+}
+
+// storedLastPush returns the most recent push ever recorded for a user.
+func storedLastPush(username string) (time.Time, error) {
+	var lastPush time.Time
+	err := db.QueryRow("SELECT pushed_at FROM activity_pushes WHERE username = ? ORDER BY pushed_at DESC LIMIT 1",
+		username).Scan(&lastPush)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("no push events found")
+	}
+	return lastPush, nil
+}
+
+// storedPushDates returns the recorded push days (UTC, YYYY-MM-DD) in
+// [start, end].
+func storedPushDates(username string, start, end time.Time) (map[string]bool, error) {
+	rows, err := db.Query(`
+		SELECT pushed_at FROM activity_pushes
+		WHERE username = ? AND pushed_at >= ? AND pushed_at < ?`,
+		username, dayStart(start), dayStart(end).AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pushDates := make(map[string]bool)
+	for rows.Next() {
+		var pushedAt time.Time
+		if err := rows.Scan(&pushedAt); err != nil {
+			return nil, err
+		}
+		pushDates[pushedAt.Format("2006-01-02")] = true
+	}
+	return pushDates, rows.Err()
+}
+
+// dayStart truncates t to midnight UTC of the same calendar date.
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// defaultTrendWeeks covers roughly one school term.
+const defaultTrendWeeks = 18
+
+// studentTrend summarises a student's recorded history.
+type studentTrend struct {
+	username      string
+	pushDays      int
+	currentStreak int
+	longestStreak int
+	weeks         map[string]int // week start (YYYY-MM-DD) -> days pushed
+}
+
+// schoolDays returns the Monday-Friday dates in [start, end].
+func schoolDays(start, end time.Time) []time.Time {
+	var days []time.Time
+	for d := dayStart(start); !d.After(dayStart(end)); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// weekOf returns the Monday starting the week that contains d.
+func weekOf(d time.Time) time.Time {
+	offset := (int(d.Weekday()) + 6) % 7
+	return dayStart(d).AddDate(0, 0, -offset)
+}
+
+// computeTrend walks the school days in range. Streaks count consecutive
+// school days with at least one push, so weekends never break a streak.
+func computeTrend(username string, pushDates map[string]bool, days []time.Time) studentTrend {
+	trend := studentTrend{username: username, weeks: make(map[string]int)}
+
+	streak := 0
+	for _, d := range days {
+		week := weekOf(d).Format("2006-01-02")
+		if _, ok := trend.weeks[week]; !ok {
+			trend.weeks[week] = 0
+		}
+
+		if !pushDates[d.Format("2006-01-02")] {
+			streak = 0
+			continue
+		}
+		trend.pushDays++
+		trend.weeks[week]++
+		streak++
+		if streak > trend.longestStreak {
+			trend.longestStreak = streak
+		}
+	}
+
+	// Today not being over yet shouldn't reset the current streak.
+	trend.currentStreak = streak
+	if n := len(days); streak == 0 && n > 1 && dayStart(days[n-1]).Equal(dayStart(time.Now().UTC())) {
+		for i := n - 2; i >= 0 && pushDates[days[i].Format("2006-01-02")]; i-- {
+			trend.currentStreak++
+		}
+	}
+	return trend
+}
+
+// classTrends computes trends for every active student from stored
+// snapshots over the last `weeks` weeks.
+func classTrends(className string, weeks int) ([]studentTrend, []string, error) {
+	usernames, err := classStudents(className)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	end := time.Now().UTC()
+	start := weekOf(end).AddDate(0, 0, -7*(weeks-1))
+	days := schoolDays(start, end)
+
+	var trends []studentTrend
+	for _, username := range usernames {
+		pushDates, err := storedPushDates(username, start, end)
+		if err != nil {
+			return nil, nil, err
+		}
+		trends = append(trends, computeTrend(username, pushDates, days))
+	}
+
+	var weekKeys []string
+	for w := start; !w.After(end); w = w.AddDate(0, 0, 7) {
+		weekKeys = append(weekKeys, w.Format("2006-01-02"))
+	}
+	return trends, weekKeys, nil
+}
+
+// trendReport renders classTrends as text: one line per student plus the
+// share of students active in each week.
+func trendReport(className string, weeks int) (string, error) {
+	trends, weekKeys, err := classTrends(className, weeks)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Activity Trends for %s (last %d weeks):\n", className, weeks))
+	sb.WriteString("----------------------------------------\n")
+	for _, t := range trends {
+		sb.WriteString(fmt.Sprintf("%s: %d push days, current streak %d, longest streak %d\n",
+			t.username, t.pushDays, t.currentStreak, t.longestStreak))
+	}
+
+	sb.WriteString("\nWeekly participation:\n")
+	for _, week := range weekKeys {
+		active := 0
+		for _, t := range trends {
+			if t.weeks[week] > 0 {
+				active++
+			}
+		}
+		sb.WriteString(fmt.Sprintf("Week of %s: %d/%d students pushed\n", week, active, len(trends)))
+	}
+	return sb.String(), nil
+}
+
+// Activity statuses, matching the Check Activity legend.
+const (
+	statusActive   = "active"   // pushed within the last 24 hours
+	statusRecent   = "recent"   // pushed within the last 72 hours
+	statusInactive = "inactive" // no push in over 72 hours
+	statusError    = "error"    // activity could not be determined
+)
+
+func pushStatus(timeSince time.Duration) string {
+	switch {
+	case timeSince < 24*time.Hour:
+		return statusActive
+	case timeSince < 72*time.Hour:
+		return statusRecent
+	default:
+		return statusInactive
+	}
+}
+
+// studentActivity is one student's row in the activity views.
+type studentActivity struct {
+	Username   string
+	LastPush   time.Time // zero when no push is known
+	Status     string
+	HoursSince float64
+	PushDays   map[string]bool // YYYY-MM-DD -> pushed, for the grid week
+	Error      string
+}
+
+// gridDays returns the dates shown in the Week History grid.
+func gridDays() []time.Time {
+	start, end := getGridDateRange()
+	var days []time.Time
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	return days
+}
+
+// classActivity builds activity rows for a class from the stored history.
+// With refresh set, stale students are fetched from GitHub first.
+func classActivity(className string, refresh bool) ([]studentActivity, error) {
+	usernames, err := classStudents(className)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := getGridDateRange()
+	var result []studentActivity
+	for _, username := range usernames {
+		row := studentActivity{Username: username}
+
+		var fetchErr error
+		if refresh {
+			fetchErr = refreshActivity(username)
+		}
+
+		row.PushDays, err = storedPushDates(username, start, end)
+		if err != nil {
+			return nil, err
+		}
+
+		lastPush, err := storedLastPush(username)
+		switch {
+		case fetchErr != nil:
+			row.Status, row.Error = statusError, fetchErr.Error()
+		case err != nil:
+			row.Status, row.Error = statusError, err.Error()
+		default:
+			since := time.Since(lastPush)
+			row.LastPush = lastPush
+			row.HoursSince = since.Hours()
+			row.Status = pushStatus(since)
+		}
+		result = append(result, row)
+	}
+	return result, nil
+}
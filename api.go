@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MarshalJSON gives activity rows the stable field names shared by the API
+// and the export formats. Unknown values are null.
+func (a studentActivity) MarshalJSON() ([]byte, error) {
+	type activityJSON struct {
+		Username   string          `json:"username"`
+		LastPush   *time.Time      `json:"last_push"`
+		Status     string          `json:"status"`
+		HoursSince *float64        `json:"hours_since"`
+		PushDays   map[string]bool `json:"push_days"`
+		Error      string          `json:"error,omitempty"`
+	}
+
+	out := activityJSON{
+		Username: a.Username,
+		Status:   a.Status,
+		PushDays: a.PushDays,
+		Error:    a.Error,
+	}
+	if !a.LastPush.IsZero() {
+		lastPush := a.LastPush.UTC()
+		hours := a.HoursSince
+		out.LastPush, out.HoursSince = &lastPush, &hours
+	}
+	return json.Marshal(out)
+}
+
+// classSummary is a class as listed by the API.
+type classSummary struct {
+	Name     string `json:"name"`
+	Students int    `json:"students"`
+}
+
+// apiToken returns the configured token, or generates one for this run.
+func apiToken(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	token := hex.EncodeToString(buf)
+	log.Printf("no API token configured, generated one for this run: %s", token)
+	return token
+}
+
+// requireToken rejects requests without "Authorization: Bearer <token>".
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, "missing or invalid API token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write API response: %v", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// classHandler resolves the {class} path value, answering 404 for unknown
+// or archived classes.
+func classHandler(fn func(w http.ResponseWriter, r *http.Request, className string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		className := r.PathValue("class")
+		if !classExists(className) {
+			writeJSONError(w, http.StatusNotFound, "class not found: "+className)
+			return
+		}
+		fn(w, r, className)
+	}
+}
+
+func apiClasses(w http.ResponseWriter, r *http.Request) {
+	names, err := activeClasses()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	classes := []classSummary{}
+	for _, name := range names {
+		usernames, err := classStudents(name)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		classes = append(classes, classSummary{Name: name, Students: len(usernames)})
+	}
+	writeJSON(w, classes)
+}
+
+func apiStudents(w http.ResponseWriter, r *http.Request, className string) {
+	roster, err := classRoster(className)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if roster == nil {
+		roster = []student{}
+	}
+	writeJSON(w, roster)
+}
+
+func apiAssignments(w http.ResponseWriter, r *http.Request, className string) {
+	list, err := classAssignments(className)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if list == nil {
+		list = []assignment{}
+	}
+	writeJSON(w, list)
+}
+
+// apiActivity serves last push times and this week's push map. Pass
+// ?refresh=true to fetch stale students from GitHub first.
+func apiActivity(w http.ResponseWriter, r *http.Request, className string) {
+	activity, err := classActivity(className, r.URL.Query().Get("refresh") == "true")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if activity == nil {
+		activity = []studentActivity{}
+	}
+	writeJSON(w, activity)
+}
+
+// registerAPI mounts the token-protected JSON API under /api/v1/.
+func registerAPI(mux *http.ServeMux, token string) {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/v1/classes", apiClasses)
+	api.HandleFunc("GET /api/v1/classes/{class}/students", classHandler(apiStudents))
+	api.HandleFunc("GET /api/v1/classes/{class}/assignments", classHandler(apiAssignments))
+	api.HandleFunc("GET /api/v1/classes/{class}/activity", classHandler(apiActivity))
+
+	mux.Handle("/api/v1/", requireToken(token, api))
+}
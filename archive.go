@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// archivedClassSuffix is added to a class's name when it is archived, so
+// the name can be used for a new class.
+const archivedClassSuffix = "@archived-"
+
+// setClassArchived archives or restores a class. Students keep their own
+// archived flag, so restoring a class brings back exactly the roster it had.
+func setClassArchived(className string, archived bool) (string, error) {
+	if archived {
+		return archiveClass(className)
+	}
+	return restoreClass(className)
+}
+
+// archiveClass archives a class under its name plus @archived-<date>.
+func archiveClass(className string) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var classID int
+	if err := tx.QueryRow("SELECT id FROM classes WHERE name = ? AND archived = 0", className).Scan(&classID); err != nil {
+		return "", fmt.Errorf("active class not found: %s", className)
+	}
+
+	base := className + archivedClassSuffix + time.Now().Format("2006-01-02")
+	name := base
+	for n := 2; ; n++ {
+		var taken bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM classes WHERE name = ?)", name).Scan(&taken); err != nil {
+			return "", err
+		}
+		if !taken {
+			break
+		}
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	if _, err := tx.Exec("UPDATE classes SET archived = 1, name = ? WHERE id = ?", name, classID); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit changes: %v", err)
+	}
+	return fmt.Sprintf("Archived class: %s and all its students as %s\n", className, name), nil
+}
+
+// restoreClass restores an archived class under its original name. It
+// takes the archived name, or the original one if only one archived class
+// had it.
+func restoreClass(className string) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id, name FROM classes WHERE archived = 1 ORDER BY name")
+	if err != nil {
+		return "", err
+	}
+	var matchIDs []int
+	var matches []string
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return "", err
+		}
+		if name == className || strings.HasPrefix(name, className+archivedClassSuffix) {
+			matchIDs, matches = append(matchIDs, id), append(matches, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	if i := slices.Index(matches, className); i >= 0 {
+		matchIDs, matches = matchIDs[i:i+1], matches[i:i+1]
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("archived class not found: %s", className)
+	case 1:
+	default:
+		return "", fmt.Errorf("several archived classes were named %s; restore one of: %s", className, strings.Join(matches, ", "))
+	}
+
+	name := matches[0]
+	if i := strings.LastIndex(name, archivedClassSuffix); i > 0 {
+		name = name[:i]
+	}
+	var taken bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM classes WHERE name = ? AND id != ?)", name, matchIDs[0]).Scan(&taken); err != nil {
+		return "", err
+	}
+	if taken {
+		return "", fmt.Errorf("a class named %s already exists; rename or delete it before restoring %s", name, matches[0])
+	}
+
+	if _, err := tx.Exec("UPDATE classes SET archived = 0, name = ? WHERE id = ?", name, matchIDs[0]); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit changes: %v", err)
+	}
+	return fmt.Sprintf("Restored class: %s\n", name), nil
+}
+
+// setStudentsArchived archives or restores students within a class.
+func setStudentsArchived(className string, usernames []string, archived bool) (string, error) {
+	var classID int
+	err := db.QueryRow("SELECT id FROM classes WHERE name = ?", className).Scan(&classID)
+	if err != nil {
+		return "", fmt.Errorf("class not found: %s", className)
+	}
+
+	verb := "Archived"
+	if !archived {
+		verb = "Restored"
+	}
+
+	var sb strings.Builder
+	for _, username := range usernames {
+		res, err := db.Exec("UPDATE students SET archived = ? WHERE username = ? AND class_id = ?",
+			archived, username, classID)
+		if err != nil {
+			return "", err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			sb.WriteString(fmt.Sprintf("Student not found: %s\n", username))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s student: %s in class: %s\n", verb, username, className))
+	}
+	return sb.String(), nil
+}
+
+// deleteClass permanently removes a class, all of its students (archived
+// or not) and its assignments.
+func deleteClass(className string) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	classID, err := classIDByName(tx, className)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec("DELETE FROM students WHERE class_id = ?", classID)
+	if err != nil {
+		return "", fmt.Errorf("failed to remove students: %v", err)
+	}
+
+	if err := deleteClassAssignments(tx, classID); err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec("DELETE FROM classes WHERE id = ?", classID)
+	if err != nil {
+		return "", fmt.Errorf("failed to remove class: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit changes: %v", err)
+	}
+
+	return fmt.Sprintf("Permanently deleted class: %s and all its students\n", className), nil
+}
+
+// purgeArchived permanently deletes every archived class (with its
+// students) and every archived student in an active class.
+func purgeArchived() (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		DELETE FROM students
+		WHERE archived = 1
+		   OR class_id IN (SELECT id FROM classes WHERE archived = 1)`)
+	if err != nil {
+		return "", fmt.Errorf("failed to purge students: %v", err)
+	}
+	students, _ := res.RowsAffected()
+
+	rows, err := tx.Query("SELECT id FROM classes WHERE archived = 1")
+	if err != nil {
+		return "", err
+	}
+	var classIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return "", err
+		}
+		classIDs = append(classIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	for _, id := range classIDs {
+		if err := deleteClassAssignments(tx, id); err != nil {
+			return "", err
+		}
+	}
+
+	res, err = tx.Exec("DELETE FROM classes WHERE archived = 1")
+	if err != nil {
+		return "", fmt.Errorf("failed to purge classes: %v", err)
+	}
+	classes, _ := res.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit changes: %v", err)
+	}
+
+	return fmt.Sprintf("Purged %d archived classes and %d students\n", classes, students), nil
+}
+
+// archivedSummary lists everything that is archived and can be restored.
+func archivedSummary() (string, error) {
+	var sb strings.Builder
+
+	rows, err := db.Query("SELECT name FROM classes WHERE archived = 1 ORDER BY name")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	sb.WriteString("Archived classes:\n")
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return "", err
+		}
+		sb.WriteString(fmt.Sprintf("- %s\n", name))
+	}
+
+	studentRows, err := db.Query(`
+		SELECT c.name, s.username
+		FROM students s
+		JOIN classes c ON s.class_id = c.id
+		WHERE s.archived = 1 AND c.archived = 0
+		ORDER BY c.name, s.username`)
+	if err != nil {
+		return "", err
+	}
+	defer studentRows.Close()
+
+	sb.WriteString("\nArchived students:\n")
+	for studentRows.Next() {
+		var className, username string
+		if err := studentRows.Scan(&className, &username); err != nil {
+			return "", err
+		}
+		sb.WriteString(fmt.Sprintf("- %s (%s)\n", username, className))
+	}
+	return sb.String(), nil
+}
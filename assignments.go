@@ -0,0 +1,213 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// assignment is a piece of work set for a class. StarterRepo optionally
+// points at the template students start from, pinned to StarterRef (a
+// branch, tag or commit; empty means the default branch). AssignedAt is
+// when work on it started, and DueAt when it is due; either is zero when
+// not set. CheckCommand is the shell command Run Checks executes in each
+// clone.
+type assignment struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	StarterRepo  string    `json:"starter_repo,omitempty"`
+	StarterRef   string    `json:"starter_ref,omitempty"`
+	AssignedAt   time.Time `json:"assigned_at,omitzero"`
+	DueAt        time.Time `json:"due_at,omitzero"`
+	CheckCommand string    `json:"check_command,omitempty"`
+}
+
+// assignmentColumns is the column list scanned by scanAssignment.
+const assignmentColumns = "a.id, a.name, a.starter_repo, a.starter_ref, a.assigned_at, a.due_at, a.check_command"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAssignment(row rowScanner) (assignment, error) {
+	var a assignment
+	var assigned, due sql.NullTime
+	err := row.Scan(&a.ID, &a.Name, &a.StarterRepo, &a.StarterRef, &assigned, &due, &a.CheckCommand)
+	a.AssignedAt, a.DueAt = assigned.Time, due.Time
+	return a, err
+}
+
+// addAssignment adds an assignment, set from now on.
+func addAssignment(className, name string) (string, error) {
+	var classID int
+	err := db.QueryRow("SELECT id FROM classes WHERE name = ? AND archived = 0", className).Scan(&classID)
+	if err != nil {
+		return "", fmt.Errorf("class not found: %s", className)
+	}
+
+	_, err = db.Exec("INSERT INTO assignments (class_id, name, assigned_at) VALUES (?, ?, ?)", classID, name, time.Now().UTC())
+	if err != nil {
+		return "", fmt.Errorf("failed to add assignment: %v", err)
+	}
+	return fmt.Sprintf("Added assignment: %s to class: %s\n", name, className), nil
+}
+
+// findAssignment looks up one assignment of an active class.
+func findAssignment(className, name string) (assignment, error) {
+	a, err := scanAssignment(db.QueryRow(`
+		SELECT `+assignmentColumns+`
+		FROM assignments a
+		JOIN classes c ON a.class_id = c.id
+		WHERE c.name = ? AND c.archived = 0 AND a.name = ?`,
+		className, name))
+	if err != nil {
+		return a, fmt.Errorf("assignment %s not found in class: %s", name, className)
+	}
+	return a, nil
+}
+
+// classAssignments returns a class's assignments in the order they were added.
+func classAssignments(className string) ([]assignment, error) {
+	rows, err := db.Query(`
+		SELECT `+assignmentColumns+`
+		FROM assignments a
+		JOIN classes c ON a.class_id = c.id
+		WHERE c.name = ? AND c.archived = 0
+		ORDER BY a.id`,
+		className)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []assignment
+	for rows.Next() {
+		a, err := scanAssignment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+	return result, rows.Err()
+}
+
+func listAssignments(className string) (string, error) {
+	list, err := classAssignments(className)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Assignments in %s:\n", className))
+	for _, a := range list {
+		sb.WriteString(fmt.Sprintf("- %s", a.Name))
+		if a.StarterRepo != "" {
+			sb.WriteString(fmt.Sprintf(" (starter: %s", a.StarterRepo))
+			if a.StarterRef != "" {
+				sb.WriteString(" @ " + a.StarterRef)
+			}
+			sb.WriteString(")")
+		}
+		if !a.AssignedAt.IsZero() {
+			sb.WriteString(" set " + a.AssignedAt.Local().Format(deadlineLayout))
+		}
+		if !a.DueAt.IsZero() {
+			sb.WriteString(" due " + a.DueAt.Local().Format(deadlineLayout))
+		}
+		if a.CheckCommand != "" {
+			sb.WriteString(fmt.Sprintf(" check: %q", a.CheckCommand))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// setStarter records the template repository an assignment starts from.
+func setStarter(className, name, repo, ref string) (string, error) {
+	a, err := findAssignment(className, name)
+	if err != nil {
+		return "", err
+	}
+	_, err = db.Exec("UPDATE assignments SET starter_repo = ?, starter_ref = ? WHERE id = ?", repo, ref, a.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to set starter: %v", err)
+	}
+	if repo == "" {
+		return fmt.Sprintf("Cleared starter for assignment: %s\n", name), nil
+	}
+	return fmt.Sprintf("Set starter for assignment: %s to %s\n", name, repo), nil
+}
+
+// setDue sets an assignment's deadline; a zero time clears it.
+func setDue(className, name string, due time.Time) (string, error) {
+	a, err := findAssignment(className, name)
+	if err != nil {
+		return "", err
+	}
+	var value any
+	if !due.IsZero() {
+		value = due.UTC()
+	}
+	if _, err := db.Exec("UPDATE assignments SET due_at = ? WHERE id = ?", value, a.ID); err != nil {
+		return "", fmt.Errorf("failed to set deadline: %v", err)
+	}
+	if due.IsZero() {
+		return fmt.Sprintf("Cleared deadline for assignment: %s\n", name), nil
+	}
+	return fmt.Sprintf("Assignment %s is due %s\n", name, due.Local().Format(deadlineLayout)), nil
+}
+
+// setAssigned sets when work on an assignment started; a zero time clears
+// it.
+func setAssigned(className, name string, assigned time.Time) (string, error) {
+	a, err := findAssignment(className, name)
+	if err != nil {
+		return "", err
+	}
+	var value any
+	if !assigned.IsZero() {
+		value = assigned.UTC()
+	}
+	if _, err := db.Exec("UPDATE assignments SET assigned_at = ? WHERE id = ?", value, a.ID); err != nil {
+		return "", fmt.Errorf("failed to set assigned date: %v", err)
+	}
+	if assigned.IsZero() {
+		return fmt.Sprintf("Cleared assigned date for assignment: %s\n", name), nil
+	}
+	return fmt.Sprintf("Assignment %s was set %s\n", name, assigned.Local().Format(deadlineLayout)), nil
+}
+
+// setCheckCommand sets the command Run Checks executes in each clone; an
+// empty command clears it.
+func setCheckCommand(className, name, command string) (string, error) {
+	a, err := findAssignment(className, name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := db.Exec("UPDATE assignments SET check_command = ? WHERE id = ?", command, a.ID); err != nil {
+		return "", fmt.Errorf("failed to set check command: %v", err)
+	}
+	if command == "" {
+		return fmt.Sprintf("Cleared check command for assignment: %s\n", name), nil
+	}
+	return fmt.Sprintf("Set check command for assignment: %s to %q\n", name, command), nil
+}
+
+// assignmentTables hold per-assignment rows that are deleted along with
+// their assignment.
+var assignmentTables = []string{"deadline_snapshots", "check_results", "grades", "grade_comments", "rubric_criteria", "feedback_issues", "review_notes"}
+
+// deleteClassAssignments removes a class's assignments and everything
+// recorded for them.
+func deleteClassAssignments(tx *sql.Tx, classID int) error {
+	for _, table := range assignmentTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE assignment_id IN (SELECT id FROM assignments WHERE class_id = ?)", classID); err != nil {
+			return fmt.Errorf("failed to remove %s: %v", table, err)
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM assignments WHERE class_id = ?", classID); err != nil {
+		return fmt.Errorf("failed to remove assignments: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// maxPreviewBytes keeps huge files (minified bundles, images) from
+// freezing the preview pane.
+const maxPreviewBytes = 256 * 1024
+
+// codeBrowser is the tview screen for reading students' cloned repositories.
+// The selected path is kept when switching students so the same file can be
+// compared across the class.
+type codeBrowser struct {
+	app        *tview.Application
+	students   []string
+	index      int
+	path       string      // selected path relative to the repository root
+	assignment *assignment // review notes are filed under it; may be nil
+
+	pages   *tview.Pages
+	header  *tview.TextView
+	tree    *tview.TreeView
+	preview *tview.TextView
+	history *tview.TextView
+}
+
+func (b *codeBrowser) username() string {
+	return b.students[b.index]
+}
+
+// loadStudent shows the repository of students[index], reselecting the
+// current path if the new repository has it.
+func (b *codeBrowser) loadStudent(index int) {
+	b.index = index
+	username := b.username()
+
+	b.header.SetText(fmt.Sprintf("[yellow]%s[white]  (%d/%d)   [gray]n/p: next/previous student · c: add review note · Tab: switch pane · Esc: back",
+		username, index+1, len(b.students)))
+
+	if !isCloned(username) {
+		root := tview.NewTreeNode(username + " (not cloned)").SetColor(tcell.ColorRed)
+		b.tree.SetRoot(root).SetCurrentNode(root)
+		b.preview.SetText("Run Clone Repositories to browse this student's code.")
+		b.history.Clear()
+		return
+	}
+
+	root := tview.NewTreeNode(username).SetColor(tcell.ColorYellow).SetReference("")
+	b.addChildren(root, "")
+	b.tree.SetRoot(root).SetCurrentNode(root)
+
+	if b.path == "" {
+		b.preview.Clear()
+		b.history.Clear()
+		return
+	}
+
+	// Walk down to the previously selected path, expanding directories.
+	node := root
+	parts := strings.Split(b.path, "/")
+	for i := range parts {
+		rel := strings.Join(parts[:i+1], "/")
+		var next *tview.TreeNode
+		for _, child := range node.GetChildren() {
+			if child.GetReference().(string) == rel {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			b.preview.SetText(fmt.Sprintf("[red]%s does not exist in %s's repository.", b.path, username))
+			b.history.Clear()
+			return
+		}
+		if i < len(parts)-1 {
+			b.addChildren(next, rel)
+			next.SetExpanded(true)
+		}
+		node = next
+	}
+	b.tree.SetCurrentNode(node)
+	b.showPath(b.path)
+}
+
+// addChildren lists a directory into the tree once. Node references hold
+// paths relative to the repository root.
+func (b *codeBrowser) addChildren(node *tview.TreeNode, rel string) {
+	if len(node.GetChildren()) > 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(filepath.Join(b.username(), rel))
+	if err != nil {
+		return
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].IsDir() && !entries[j].IsDir()
+	})
+
+	for _, entry := range entries {
+		if entry.Name() == ".git" {
+			continue
+		}
+		child := tview.NewTreeNode(entry.Name()).SetReference(filepath.ToSlash(filepath.Join(rel, entry.Name())))
+		if entry.IsDir() {
+			child.SetText(entry.Name() + "/").SetColor(tcell.ColorGreen).SetExpanded(false)
+		}
+		node.AddChild(child)
+	}
+}
+
+// showPath previews a file (or clears the panes for a directory) and loads
+// its git history.
+func (b *codeBrowser) showPath(rel string) {
+	full := filepath.Join(b.username(), rel)
+	info, err := os.Stat(full)
+	if err != nil {
+		b.preview.SetText(fmt.Sprintf("[red]%v", err))
+		return
+	}
+
+	b.preview.Clear()
+	b.preview.ScrollToBeginning()
+	if info.IsDir() {
+		b.preview.SetText(fmt.Sprintf("[gray]%s/ (directory)", rel))
+	} else {
+		b.preview.SetText(highlightFile(full))
+	}
+
+	b.history.SetText(fileHistory(b.username(), rel) + b.fileNotes(rel))
+}
+
+// fileNotes lists the review notes on a file for the history pane.
+func (b *codeBrowser) fileNotes(rel string) string {
+	notes, err := reviewNotes(reviewFilter{Username: b.username(), Path: rel})
+	if err != nil || len(notes) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n[yellow]Review notes:[-]\n")
+	for _, n := range notes {
+		sb.WriteString(fmt.Sprintf("#%d L%s @ %.7s: %s\n", n.ID, n.Lines(), n.SHA, tview.Escape(n.Body)))
+	}
+	return sb.String()
+}
+
+// addNoteForm asks for a line range and a note on the selected file.
+func (b *codeBrowser) addNoteForm() {
+	full := filepath.Join(b.username(), b.path)
+	if info, err := os.Stat(full); b.path == "" || err != nil || info.IsDir() {
+		return
+	}
+
+	form := tview.NewForm()
+	lines := tview.NewInputField().SetLabel("Lines").SetPlaceholder("12 or 12-18").SetFieldWidth(12)
+	body := tview.NewInputField().SetLabel("Note")
+	close := func() {
+		b.pages.RemovePage("note")
+		b.app.SetFocus(b.tree)
+	}
+	form.AddFormItem(lines).AddFormItem(body).
+		AddButton("Save", func() {
+			_, err := addReviewNote(b.username(), b.assignment, b.path, lines.GetText(), body.GetText())
+			if err != nil {
+				form.SetTitle(" " + err.Error() + " ").SetTitleColor(tcell.ColorRed)
+				return
+			}
+			close()
+			b.showPath(b.path)
+		}).
+		AddButton("Cancel", close)
+	form.SetCancelFunc(close)
+	form.SetBorder(true).SetTitle(fmt.Sprintf(" Review note on %s ", b.path))
+
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(form, 9, 0, true).
+			AddItem(nil, 0, 1, false), 80, 0, true).
+		AddItem(nil, 0, 1, false)
+	b.pages.AddPage("note", modal, true, true)
+	b.app.SetFocus(form)
+}
+
+// highlightFile returns the file's contents with line numbers and
+// syntax highlighting as tview colour tags.
+func highlightFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Sprintf("[red]%v", err)
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "[gray](binary file)"
+	}
+
+	truncated := false
+	if len(data) > maxPreviewBytes {
+		data, truncated = data[:maxPreviewBytes], true
+	}
+
+	lexer := lexers.Match(filepath.Base(path))
+	if lexer == nil {
+		lexer = lexers.Analyse(string(data))
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	text := tview.Escape(string(data))
+	if iterator, err := chroma.Coalesce(lexer).Tokenise(nil, string(data)); err == nil {
+		text = colorTokens(iterator, styles.Get("monokai"))
+	}
+
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	var sb strings.Builder
+	for i, line := range lines {
+		sb.WriteString(fmt.Sprintf("[gray]%4d[-] %s\n", i+1, line))
+	}
+	if truncated {
+		sb.WriteString(fmt.Sprintf("[yellow]... truncated at %d KB", maxPreviewBytes/1024))
+	}
+	return sb.String()
+}
+
+// colorTokens converts highlighted tokens to tview colour tags. Tags are
+// closed at every line break so each line can be prefixed independently.
+func colorTokens(iterator chroma.Iterator, style *chroma.Style) string {
+	var sb strings.Builder
+	for token := iterator(); token != chroma.EOF; token = iterator() {
+		colour := style.Get(token.Type).Colour
+		for i, part := range strings.Split(token.Value, "\n") {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			if part == "" {
+				continue
+			}
+			if colour.IsSet() {
+				sb.WriteString("[" + colour.String() + "]" + tview.Escape(part) + "[-]")
+			} else {
+				sb.WriteString(tview.Escape(part))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// fileHistory returns the recent commits touching a path in a clone.
+func fileHistory(username, rel string) string {
+	commits, err := vcs.Log(username, rel, 20)
+	if err != nil {
+		return fmt.Sprintf("[red]git log failed: %v", err)
+	}
+	if len(commits) == 0 {
+		return "[gray](no commits)"
+	}
+	var sb strings.Builder
+	for _, c := range commits {
+		sb.WriteString(fmt.Sprintf("%.7s %s %s: %s\n", c.SHA, c.Authored.Format("2006-01-02"), c.Author, c.Subject))
+	}
+	return tview.Escape(sb.String())
+}
+
+// showCodeBrowser runs the code browser over a class, starting with the
+// given student (or the first one). Review notes written in the browser are
+// filed under assignmentName when it is set.
+func showCodeBrowser(className, startUser, assignmentName string) error {
+	students, err := classStudents(className)
+	if err != nil {
+		return err
+	}
+	a, err := findReviewAssignment(className, assignmentName)
+	if err != nil {
+		return err
+	}
+	if len(students) == 0 {
+		return fmt.Errorf("no students in class: %s", className)
+	}
+
+	start := 0
+	if startUser != "" {
+		start = -1
+		for i, u := range students {
+			if u == startUser {
+				start = i
+			}
+		}
+		if start < 0 {
+			return fmt.Errorf("student %s not found in class: %s", startUser, className)
+		}
+	}
+
+	b := &codeBrowser{
+		app:        tview.NewApplication(),
+		students:   students,
+		assignment: a,
+		pages:      tview.NewPages(),
+		header:     tview.NewTextView().SetDynamicColors(true),
+		tree:       tview.NewTreeView(),
+		preview:    tview.NewTextView().SetDynamicColors(true).SetWrap(false),
+		history:    tview.NewTextView().SetDynamicColors(true),
+	}
+	b.tree.SetBorder(true).SetTitle("Files")
+	b.preview.SetBorder(true).SetTitle("Preview")
+	b.history.SetBorder(true).SetTitle("History")
+
+	b.tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		rel, ok := node.GetReference().(string)
+		if !ok || rel == "" {
+			return
+		}
+		b.addChildren(node, rel)
+		node.SetExpanded(!node.IsExpanded())
+		b.path = rel
+		b.showPath(rel)
+	})
+
+	right := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(b.preview, 0, 3, false).
+		AddItem(b.history, 0, 1, false)
+	body := tview.NewFlex().
+		AddItem(b.tree, 0, 1, true).
+		AddItem(right, 0, 3, false)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(b.header, 1, 0, false).
+		AddItem(body, 0, 1, true)
+
+	layout.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape:
+			b.app.Stop()
+			return nil
+		case event.Key() == tcell.KeyTab:
+			if b.tree.HasFocus() {
+				b.app.SetFocus(b.preview)
+			} else {
+				b.app.SetFocus(b.tree)
+			}
+			return nil
+		case event.Rune() == 'n':
+			b.loadStudent((b.index + 1) % len(b.students))
+			return nil
+		case event.Rune() == 'p':
+			b.loadStudent((b.index + len(b.students) - 1) % len(b.students))
+			return nil
+		case event.Rune() == 'c':
+			b.addNoteForm()
+			return nil
+		}
+		return event
+	})
+
+	b.pages.AddPage("browser", layout, true, true)
+	b.loadStudent(start)
+	return b.app.SetRoot(b.pages, true).Run()
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultCheckTimeout stops a check that hangs, e.g. a server that
+	// never exits or a test waiting for input.
+	defaultCheckTimeout = 2 * time.Minute
+
+	// maxCheckOutput is how much of a check's output is kept.
+	maxCheckOutput = 64 * 1024
+)
+
+// scoreLine lets a check report a score by printing e.g. "SCORE: 8/10" or
+// "score: 92". The last such line wins.
+var scoreLine = regexp.MustCompile(`(?im)^\s*score:\s*([0-9]+(?:\.[0-9]+)?)\s*(?:/\s*([0-9]+(?:\.[0-9]+)?))?\s*$`)
+
+// checkResult is the outcome of running an assignment's check command in
+// a student's clone.
+type checkResult struct {
+	Username string
+	SHA      string
+	RanAt    time.Time
+	Passed   bool
+	ExitCode int
+	TimedOut bool
+	Limit    string // the sandbox limit that stopped the check, if any
+	Duration time.Duration
+	Score    sql.NullFloat64
+	MaxScore sql.NullFloat64
+	Output   string
+	Sandbox  string // isolation notes for this run; not stored
+}
+
+// MarshalJSON uses the same field names as the csv/tsv export, plus the
+// captured output.
+func (r checkResult) MarshalJSON() ([]byte, error) {
+	type checkJSON struct {
+		Username  string   `json:"username"`
+		Passed    bool     `json:"passed"`
+		ExitCode  int      `json:"exit_code"`
+		TimedOut  bool     `json:"timed_out"`
+		Limit     string   `json:"limit_hit"`
+		Score     *float64 `json:"score"`
+		MaxScore  *float64 `json:"max_score"`
+		DurationS float64  `json:"duration_s"`
+		SHA       string   `json:"sha"`
+		RanAt     string   `json:"ran_at"`
+		Output    string   `json:"output"`
+	}
+
+	out := checkJSON{
+		Username:  r.Username,
+		Passed:    r.Passed,
+		ExitCode:  r.ExitCode,
+		TimedOut:  r.TimedOut,
+		Limit:     r.Limit,
+		DurationS: r.Duration.Seconds(),
+		SHA:       r.SHA,
+		RanAt:     r.RanAt.UTC().Format(time.RFC3339),
+		Output:    r.Output,
+	}
+	if r.Score.Valid {
+		out.Score = &r.Score.Float64
+	}
+	if r.MaxScore.Valid {
+		out.MaxScore = &r.MaxScore.Float64
+	}
+	return json.Marshal(out)
+}
+
+func (r checkResult) scoreText() string {
+	if !r.Score.Valid {
+		return ""
+	}
+	score := strconv.FormatFloat(r.Score.Float64, 'f', -1, 64)
+	if r.MaxScore.Valid {
+		score += "/" + strconv.FormatFloat(r.MaxScore.Float64, 'f', -1, 64)
+	}
+	return score
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the
+// rest, so a runaway check can't fill memory.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if room := c.limit - c.buf.Len(); room < len(p) {
+		c.buf.Write(p[:max(room, 0)])
+		c.truncated = true
+		return len(p), nil
+	}
+	return c.buf.Write(p)
+}
+
+func (c *cappedBuffer) String() string {
+	if c.truncated {
+		return c.buf.String() + fmt.Sprintf("\n... output truncated at %d KB", c.limit/1024)
+	}
+	return c.buf.String()
+}
+
+// shellCommand runs a command line through the platform's shell.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+func parseScore(output string) (score, maxScore sql.NullFloat64) {
+	matches := scoreLine.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return
+	}
+	m := matches[len(matches)-1]
+	score.Float64, _ = strconv.ParseFloat(m[1], 64)
+	score.Valid = true
+	if m[2] != "" {
+		maxScore.Float64, _ = strconv.ParseFloat(m[2], 64)
+		maxScore.Valid = true
+	}
+	return
+}
+
+// runCheck runs the check command in a sandboxed copy of a student's
+// clone. The command gets SCV_STUDENT and CI=true in its environment.
+func runCheck(username string, a assignment, limits sandboxLimits) checkResult {
+	result := checkResult{Username: username, RanAt: time.Now()}
+	result.SHA, _ = vcs.Head(username)
+
+	run, err := newSandboxRun(username)
+	if err != nil {
+		result.ExitCode, result.Output = -1, err.Error()
+		return result
+	}
+	defer run.cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), limits.Timeout)
+	defer cancel()
+
+	var out cappedBuffer
+	out.limit = maxCheckOutput
+	cmd, err := run.start(ctx, a.CheckCommand, limits, &out)
+	if err == nil {
+		err = cmd.Wait()
+		killProcessGroup(cmd)
+	}
+	result.Duration = time.Since(result.RanAt)
+	result.Output = out.String()
+	result.Sandbox = strings.Join(run.notes, ", ")
+	result.Limit = limitHit(ctx, err, result.Output)
+	result.TimedOut = result.Limit == limitTime
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.Passed = true
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		result.ExitCode = -1
+		result.Output += "\n" + err.Error()
+	}
+	result.Passed = result.Passed && result.Limit == ""
+	result.Score, result.MaxScore = parseScore(result.Output)
+	return result
+}
+
+func saveCheckResult(a assignment, r checkResult) error {
+	_, err := db.Exec(`
+		INSERT INTO check_results (assignment_id, username, ran_at, sha, passed, exit_code, timed_out, limit_hit, duration_ms, score, max_score, output)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(assignment_id, username) DO UPDATE SET
+			ran_at = excluded.ran_at, sha = excluded.sha, passed = excluded.passed,
+			exit_code = excluded.exit_code, timed_out = excluded.timed_out, limit_hit = excluded.limit_hit, duration_ms = excluded.duration_ms,
+			score = excluded.score, max_score = excluded.max_score, output = excluded.output`,
+		a.ID, r.Username, r.RanAt.UTC(), r.SHA, r.Passed, r.ExitCode, r.TimedOut, r.Limit,
+		r.Duration.Milliseconds(), r.Score, r.MaxScore, r.Output)
+	return err
+}
+
+// checkLine is one student's line in the Run Checks summary.
+func checkLine(r checkResult) string {
+	var status string
+	switch {
+	case r.TimedOut:
+		status = fmt.Sprintf("%s %s: timed out after %s", iconError, r.Username, r.Duration.Round(time.Second))
+	case r.Limit != "":
+		status = fmt.Sprintf("%s %s: stopped by the %s limit after %s", iconError, r.Username, r.Limit, r.Duration.Round(100*time.Millisecond))
+	case r.Passed:
+		status = fmt.Sprintf("%s %s: passed in %s", iconSuccess, r.Username, r.Duration.Round(100*time.Millisecond))
+	default:
+		status = fmt.Sprintf("%s %s: failed (exit %d) in %s", iconError, r.Username, r.ExitCode, r.Duration.Round(100*time.Millisecond))
+	}
+	if score := r.scoreText(); score != "" {
+		status += ", score " + score
+	}
+	return status
+}
+
+// runChecks runs an assignment's check command against every cloned
+// student repository and stores the results.
+func runChecks(className, assignmentName string, limits sandboxLimits) (string, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return "", err
+	}
+	if a.CheckCommand == "" {
+		return "", fmt.Errorf("assignment %s has no check command; set one with scv set-check", a.Name)
+	}
+	usernames, err := classStudents(className)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Checks for %s (%s):\n", a.Name, a.CheckCommand))
+	sb.WriteString("Sandbox: " + limits.describe() + "\n")
+	sb.WriteString("----------------------------------------\n")
+	passed, failed, skipped := 0, 0, 0
+	notes := make(map[string]bool)
+	for _, username := range usernames {
+		if !isCloned(username) {
+			sb.WriteString(fmt.Sprintf("%s %s: not cloned\n", iconWarning, username))
+			skipped++
+			continue
+		}
+		r := runCheck(username, a, limits)
+		if r.Sandbox != "" {
+			notes[r.Sandbox] = true
+		}
+		if err := saveCheckResult(a, r); err != nil {
+			return "", fmt.Errorf("failed to save check result: %v", err)
+		}
+		if r.Passed {
+			passed++
+		} else {
+			failed++
+		}
+		sb.WriteString(checkLine(r) + "\n")
+	}
+	sb.WriteString(fmt.Sprintf("\n%d passed, %d failed, %d not cloned\n", passed, failed, skipped))
+	for note := range notes {
+		sb.WriteString("Isolation: " + note + "\n")
+	}
+	return sb.String(), nil
+}
+
+// storedCheckResults returns the latest results of an assignment's check,
+// in roster order.
+func storedCheckResults(className, assignmentName string) (assignment, []checkResult, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return a, nil, err
+	}
+	rows, err := db.Query(`
+		SELECT r.username, r.sha, r.ran_at, r.passed, r.exit_code, r.timed_out, r.limit_hit, r.duration_ms, r.score, r.max_score, r.output
+		FROM check_results r
+		JOIN assignments a ON r.assignment_id = a.id
+		JOIN students s ON s.username = r.username AND s.class_id = a.class_id AND s.archived = 0
+		WHERE r.assignment_id = ?
+		ORDER BY r.username`,
+		a.ID)
+	if err != nil {
+		return a, nil, err
+	}
+	defer rows.Close()
+
+	var results []checkResult
+	for rows.Next() {
+		var r checkResult
+		var ms int64
+		err := rows.Scan(&r.Username, &r.SHA, &r.RanAt, &r.Passed, &r.ExitCode, &r.TimedOut, &r.Limit, &ms, &r.Score, &r.MaxScore, &r.Output)
+		if err != nil {
+			return a, nil, err
+		}
+		r.Duration = time.Duration(ms) * time.Millisecond
+		results = append(results, r)
+	}
+	return a, results, rows.Err()
+}
+
+func checkTable(results []checkResult) table {
+	t := table{header: []string{"username", "passed", "exit_code", "timed_out", "limit_hit", "score", "max_score", "duration_s", "sha", "ran_at"}}
+	for _, r := range results {
+		score, maxScore := "", ""
+		if r.Score.Valid {
+			score = strconv.FormatFloat(r.Score.Float64, 'f', -1, 64)
+		}
+		if r.MaxScore.Valid {
+			maxScore = strconv.FormatFloat(r.MaxScore.Float64, 'f', -1, 64)
+		}
+		t.rows = append(t.rows, []string{
+			r.Username, strconv.FormatBool(r.Passed), strconv.Itoa(r.ExitCode), strconv.FormatBool(r.TimedOut), r.Limit,
+			score, maxScore, strconv.FormatFloat(r.Duration.Seconds(), 'f', 1, 64), r.SHA, r.RanAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return t
+}
@@ -0,0 +1,1051 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+)
+
+// rootCmd starts the interactive menu when scv is run without a subcommand.
+var rootCmd = &cobra.Command{
+	Use:          "scv",
+	Short:        "Manage and track student code submissions on GitHub",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p := tea.NewProgram(initialModel())
+		if _, err := p.Run(); err != nil {
+			return fmt.Errorf("error running program: %v", err)
+		}
+		return nil
+	},
+}
+
+var moveStudentCmd = &cobra.Command{
+	Use:   "move-student <from-class> <to-class> <username>...",
+	Short: "Move (or copy) students from one class to another",
+	Args:  cobra.MinimumNArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		duplicate, _ := cmd.Flags().GetBool("copy")
+		return printResult(transferStudents(args[2:], args[0], args[1], duplicate))
+	},
+}
+
+var archiveClassCmd = &cobra.Command{
+	Use:   "archive-class <class>",
+	Short: "Archive a class so it is hidden from default lists",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(setClassArchived(args[0], true))
+	},
+}
+
+var restoreClassCmd = &cobra.Command{
+	Use:   "restore-class <class>",
+	Short: "Restore an archived class",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(setClassArchived(args[0], false))
+	},
+}
+
+var archiveStudentCmd = &cobra.Command{
+	Use:   "archive-student <class> <username>...",
+	Short: "Archive students in a class",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(setStudentsArchived(args[0], args[1:], true))
+	},
+}
+
+var restoreStudentCmd = &cobra.Command{
+	Use:   "restore-student <class> <username>...",
+	Short: "Restore archived students in a class",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(setStudentsArchived(args[0], args[1:], false))
+	},
+}
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Permanently delete all archived classes and students",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+			summary, err := archivedSummary()
+			if err != nil {
+				return err
+			}
+			fmt.Print(summary)
+			if !confirm("\nPermanently delete everything listed above?") {
+				fmt.Println("Aborted.")
+				return nil
+			}
+		}
+		return printResult(purgeArchived())
+	},
+}
+
+var trendsCmd = &cobra.Command{
+	Use:   "trends <class>",
+	Short: "Show activity streaks and weekly participation from stored history",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		weeks, _ := cmd.Flags().GetInt("weeks")
+		if weeks < 1 {
+			return fmt.Errorf("--weeks must be at least 1")
+		}
+		return printResult(trendReport(args[0], weeks))
+	},
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Periodically pull repositories and fetch activity for every class",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		allowReset, _ := cmd.Flags().GetBool("allow-reset")
+		if once, _ := cmd.Flags().GetBool("once"); once {
+			return syncAll(allowReset)
+		}
+
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if interval < time.Minute {
+			return fmt.Errorf("--interval must be at least 1m")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runDaemon(ctx, interval, allowReset)
+	},
+}
+
+var syncStatusCmd = &cobra.Command{
+	Use:   "sync-status",
+	Short: "Show when each class was last synced by the daemon",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(syncStatus())
+	},
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a web dashboard and JSON API of classes and activity",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _ := cmd.Flags().GetString("addr")
+		refresh, _ := cmd.Flags().GetDuration("refresh")
+		if refresh < time.Second {
+			return fmt.Errorf("--refresh must be at least 1s")
+		}
+
+		token, _ := cmd.Flags().GetString("token")
+		mux := dashboardMux(refresh)
+		registerAPI(mux, apiToken(token))
+
+		log.Printf("dashboard listening on http://%s", addr)
+		return http.ListenAndServe(addr, mux)
+	},
+}
+
+var addAssignmentCmd = &cobra.Command{
+	Use:   "add-assignment <class> <name>",
+	Short: "Add an assignment to a class",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(addAssignment(args[0], args[1]))
+	},
+}
+
+var listAssignmentsCmd = &cobra.Command{
+	Use:   "list-assignments <class>",
+	Short: "List the assignments in a class",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(listAssignments(args[0]))
+	},
+}
+
+var setStarterCmd = &cobra.Command{
+	Use:   "set-starter <class> <assignment> <repo-url> [ref]",
+	Short: "Set the starter repo (and optional branch, tag or commit) for an assignment",
+	Args:  cobra.RangeArgs(3, 4),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var ref string
+		if len(args) == 4 {
+			ref = args[3]
+		}
+		return printResult(setStarter(args[0], args[1], args[2], ref))
+	},
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <class> <assignment>",
+	Short: "Show what each student changed relative to the assignment's starter",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if stat, _ := cmd.Flags().GetBool("stat"); stat {
+			return printResult(diffSummary(args[0], args[1]))
+		}
+		return showDiffViewer(args[0], args[1])
+	},
+}
+
+var setDueCmd = &cobra.Command{
+	Use:   "set-due <class> <assignment> <deadline>",
+	Short: "Set when an assignment is due (YYYY-MM-DD, \"YYYY-MM-DD HH:MM\" or \"none\")",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var due time.Time
+		if args[2] != "none" {
+			var err error
+			if due, err = parseDeadline(args[2]); err != nil {
+				return err
+			}
+		}
+		return printResult(setDue(args[0], args[1], due))
+	},
+}
+
+var setAssignedCmd = &cobra.Command{
+	Use:   "set-assigned <class> <assignment> <date>",
+	Short: "Set when work on an assignment started (YYYY-MM-DD, \"YYYY-MM-DD HH:MM\" or \"none\")",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var assigned time.Time
+		if args[2] != "none" {
+			var err error
+			if assigned, err = parseAssigned(args[2]); err != nil {
+				return err
+			}
+		}
+		return printResult(setAssigned(args[0], args[1], assigned))
+	},
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot <class> <assignment>",
+	Short: "Tag and record each student's last commit before the deadline",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(snapshotDeadline(args[0], args[1]))
+	},
+}
+
+var submissionsCmd = &cobra.Command{
+	Use:   "submissions <class> <assignment>",
+	Short: "Show who has started, submitted on time or submitted late",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := formatFlag(cmd)
+		if err != nil {
+			return err
+		}
+		a, subs, err := classSubmissions(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case formatText:
+			fmt.Print(submissionText(a, subs))
+			return nil
+		case formatJSON:
+			if subs == nil {
+				subs = []submission{}
+			}
+			return writeJSONTo(os.Stdout, subs)
+		}
+		return submissionTable(subs).write(os.Stdout, format)
+	},
+}
+
+var setCheckCmd = &cobra.Command{
+	Use:   "set-check <class> <assignment> <command>",
+	Short: "Set the shell command Run Checks executes in each clone (e.g. \"npm test\")",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(setCheckCommand(args[0], args[1], args[2]))
+	},
+}
+
+var runChecksCmd = &cobra.Command{
+	Use:   "run-checks <class> <assignment>",
+	Short: "Run an assignment's check command in every cloned repository",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limits := defaultSandboxLimits
+		limits.Timeout, _ = cmd.Flags().GetDuration("timeout")
+		limits.CPU, _ = cmd.Flags().GetDuration("cpu")
+		limits.MemoryMB, _ = cmd.Flags().GetInt64("memory")
+		limits.Network, _ = cmd.Flags().GetBool("allow-network")
+		if limits.Timeout <= 0 || limits.CPU < time.Second || limits.MemoryMB <= 0 {
+			return fmt.Errorf("--timeout, --cpu and --memory must be positive (--cpu at least 1s)")
+		}
+		return printResult(runChecks(args[0], args[1], limits))
+	},
+}
+
+var addCriterionCmd = &cobra.Command{
+	Use:   "add-criterion <class> <assignment> <name> <points>",
+	Short: "Add a rubric criterion to an assignment (or change its points)",
+	Args:  cobra.ExactArgs(4),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		points, err := strconv.ParseFloat(args[3], 64)
+		if err != nil {
+			return fmt.Errorf("invalid points %q", args[3])
+		}
+		return printResult(addCriterion(args[0], args[1], args[2], points))
+	},
+}
+
+var removeCriterionCmd = &cobra.Command{
+	Use:   "remove-criterion <class> <assignment> <name>",
+	Short: "Remove a rubric criterion and the scores given for it",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(removeCriterion(args[0], args[1], args[2]))
+	},
+}
+
+var rubricCmd = &cobra.Command{
+	Use:   "rubric <class> <assignment>",
+	Short: "Show an assignment's rubric",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(showRubric(args[0], args[1]))
+	},
+}
+
+var gradeCmd = &cobra.Command{
+	Use:   "grade <class> <assignment> [username criterion score [comment]]",
+	Short: "Open the grading screen, or set one criterion's score",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 && len(args) != 5 && len(args) != 6 {
+			return fmt.Errorf("expected <class> <assignment>, optionally followed by <username> <criterion> <score> [comment]")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 2 {
+			return showGradingScreen(args[0], args[1])
+		}
+		var comment string
+		if len(args) == 6 {
+			comment = args[5]
+		}
+		return printResult(gradeStudent(args[0], args[1], args[2], args[3], args[4], comment))
+	},
+}
+
+var gradeCommentCmd = &cobra.Command{
+	Use:   "grade-comment <class> <assignment> <username> <comment>",
+	Short: "Set a student's overall feedback on an assignment (\"\" clears it)",
+	Args:  cobra.ExactArgs(4),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(setGradeComment(args[0], args[1], args[2], args[3]))
+	},
+}
+
+var gradebookCmd = &cobra.Command{
+	Use:   "gradebook <class> [assignment]",
+	Short: "Export totals per assignment, or one assignment's scores per criterion",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := formatFlag(cmd)
+		if err != nil {
+			return err
+		}
+		var t table
+		if len(args) == 2 {
+			t, err = assignmentGradebook(args[0], args[1])
+		} else {
+			t, err = classGradebook(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		if format == formatJSON {
+			return writeJSONTo(os.Stdout, t.records())
+		}
+		return t.write(os.Stdout, format)
+	},
+}
+
+var sendFeedbackCmd = &cobra.Command{
+	Use:   "send-feedback <class> <assignment> [username...]",
+	Short: "Post graded feedback as an issue on each student's repository",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := formatFlag(cmd)
+		if err != nil {
+			return err
+		}
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		results, preview, err := sendFeedback(args[0], args[1], args[2:], dryRun)
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case formatText, formatPlain:
+			fmt.Print(preview)
+			if dryRun {
+				fmt.Println("Dry run: nothing was posted.")
+			}
+		case formatJSON:
+			if results == nil {
+				results = []feedbackResult{}
+			}
+			return writeJSONTo(os.Stdout, results)
+		}
+		return feedbackTable(results).write(os.Stdout, format)
+	},
+}
+
+var feedbackIssuesCmd = &cobra.Command{
+	Use:   "feedback-issues <class> <assignment>",
+	Short: "List the issues feedback was posted to",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := formatFlag(cmd)
+		if err != nil {
+			return err
+		}
+		issues, err := feedbackIssues(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		if format == formatJSON {
+			if issues == nil {
+				issues = []feedbackIssue{}
+			}
+			return writeJSONTo(os.Stdout, issues)
+		}
+		t := table{header: []string{"username", "number", "url", "posted_at"}}
+		for _, issue := range issues {
+			t.rows = append(t.rows, []string{issue.Username, strconv.Itoa(issue.Number), issue.URL, issue.PostedAt.UTC().Format(time.RFC3339)})
+		}
+		return t.write(os.Stdout, format)
+	},
+}
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone <class>",
+	Short: "Clone every student's repository that isn't cloned yet",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		assignmentName, _ := cmd.Flags().GetString("assignment")
+		return printResult(cloneClass(args[0], assignmentName))
+	},
+}
+
+var cloneOptionsCmd = &cobra.Command{
+	Use:   "clone-options <class> [assignment]",
+	Short: "Show or set shallow, partial, sparse and single-branch clone options",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		className, assignmentName := args[0], ""
+		if len(args) == 2 {
+			assignmentName = args[1]
+		}
+		// An assignment's own options are edited, without the class's
+		// merged in.
+		o, err := classCloneOptions(className, "")
+		if assignmentName != "" {
+			o, err = assignmentCloneOptions(className, assignmentName)
+		}
+		if err != nil {
+			return err
+		}
+
+		flags := cmd.Flags()
+		if flags.NFlag() == 0 {
+			target := className
+			if assignmentName != "" {
+				target = "assignment " + assignmentName
+			}
+			fmt.Printf("Clone options for %s: %s\n", target, o)
+			return nil
+		}
+		if flags.Changed("depth") {
+			o.Depth, _ = flags.GetInt("depth")
+		}
+		if flags.Changed("filter") {
+			o.Filter, _ = flags.GetString("filter")
+		}
+		if flags.Changed("sparse") {
+			sparse, _ := flags.GetString("sparse")
+			o.Sparse = parseSparsePaths(sparse)
+		}
+		if flags.Changed("single-branch") {
+			o.SingleBranch, _ = flags.GetBool("single-branch")
+		}
+		return printResult(setCloneOptions(className, assignmentName, o))
+	},
+}
+
+var setPullPolicyCmd = &cobra.Command{
+	Use:   "set-pull-policy <class> <skip|stash|reset|reclone>",
+	Short: "Choose what Pull Changes does with dirty, diverged or misconfigured clones",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(setPullPolicy(args[0], args[1]))
+	},
+}
+
+var setRepoPatternCmd = &cobra.Command{
+	Use:   "set-repo-pattern <class> <pattern>",
+	Short: "Set the repository each student in a class is expected to have, e.g. {user}.github.io",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(setRepoPattern(args[0], repoPattern(args[1])))
+	},
+}
+
+var pullCmd = &cobra.Command{
+	Use:   "pull <class>",
+	Short: "Fast-forward every clone, applying the class's pull policy to those that can't be",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := formatFlag(cmd)
+		if err != nil {
+			return err
+		}
+		assignmentName, _ := cmd.Flags().GetString("assignment")
+		policy, _ := cmd.Flags().GetString("policy")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		results, policy, err := pullClass(args[0], assignmentName, policy, dryRun)
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case formatText:
+			fmt.Print(pullText(args[0], policy, results, dryRun))
+			return nil
+		case formatJSON:
+			if results == nil {
+				results = []pullResult{}
+			}
+			return writeJSONTo(os.Stdout, results)
+		}
+		return pullTable(results).write(os.Stdout, format)
+	},
+}
+
+var healthCmd = &cobra.Command{
+	Use:   "health <class>",
+	Short: "Check that each repository exists, has Pages built and matches the local clone",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := formatFlag(cmd)
+		if err != nil {
+			return err
+		}
+		refresh, _ := cmd.Flags().GetBool("refresh")
+		result, err := classRepoHealth(args[0], refresh)
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case formatText:
+			fmt.Print(repoHealthText(args[0], result))
+			return nil
+		case formatJSON:
+			if result == nil {
+				result = []repoHealth{}
+			}
+			return writeJSONTo(os.Stdout, result)
+		}
+		return repoHealthTable(result).write(os.Stdout, format)
+	},
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint <class> [username]",
+	Short: "Check Pages sites for broken links, missing images and HTML/CSS errors",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := formatFlag(cmd)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 2 {
+			if err := requireStudent(args[0], args[1]); err != nil {
+				return err
+			}
+			r := lintSite(args[1])
+			switch format {
+			case formatText, formatPlain:
+				fmt.Print(lintDetails(r))
+				return nil
+			case formatJSON:
+				return writeJSONTo(os.Stdout, r)
+			}
+			t := table{header: []string{"file", "line", "kind", "message"}}
+			for _, issue := range r.Issues {
+				t.rows = append(t.rows, []string{issue.File, strconv.Itoa(issue.Line), issue.Kind, issue.Message})
+			}
+			return t.write(os.Stdout, format)
+		}
+
+		results, err := lintClass(args[0])
+		if err != nil {
+			return err
+		}
+		switch format {
+		case formatText:
+			fmt.Print(lintText(args[0], results))
+			return nil
+		case formatJSON:
+			if results == nil {
+				results = []siteLint{}
+			}
+			return writeJSONTo(os.Stdout, results)
+		}
+		return lintTable(results).write(os.Stdout, format)
+	},
+}
+
+var checkResultsCmd = &cobra.Command{
+	Use:   "check-results <class> <assignment> [username]",
+	Short: "Show stored check results, or one student's full output",
+	Args:  cobra.RangeArgs(2, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := formatFlag(cmd)
+		if err != nil {
+			return err
+		}
+		_, results, err := storedCheckResults(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 3 {
+			for _, r := range results {
+				if r.Username == args[2] {
+					fmt.Printf("%s\nRan %s at %.7s\n\n%s\n", checkLine(r), r.RanAt.Local().Format(deadlineLayout), r.SHA, r.Output)
+					return nil
+				}
+			}
+			return fmt.Errorf("no check results for %s", args[2])
+		}
+
+		if format == formatJSON {
+			if results == nil {
+				results = []checkResult{}
+			}
+			return writeJSONTo(os.Stdout, results)
+		}
+		return checkTable(results).write(os.Stdout, format)
+	},
+}
+
+var similarityCmd = &cobra.Command{
+	Use:   "similarity <class> [assignment]",
+	Short: "Find pairs of students whose cloned code is suspiciously similar",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		threshold, _ := cmd.Flags().GetFloat64("threshold")
+		if threshold <= 0 || threshold > 1 {
+			return fmt.Errorf("--threshold must be between 0 and 1")
+		}
+		var assignmentName string
+		if len(args) == 2 {
+			assignmentName = args[1]
+		}
+		return printResult(similarityReport(args[0], assignmentName, threshold))
+	},
+}
+
+var listStudentsCmd = &cobra.Command{
+	Use:   "list-students <class>",
+	Short: "List the students in a class",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := formatFlag(cmd)
+		if err != nil {
+			return err
+		}
+		roster, err := classRoster(args[0])
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case formatText:
+			fmt.Print(rosterText(args[0], roster))
+			return nil
+		case formatJSON:
+			if roster == nil {
+				roster = []student{}
+			}
+			return writeJSONTo(os.Stdout, roster)
+		}
+		return rosterTable(roster).write(os.Stdout, format)
+	},
+}
+
+var checkActivityCmd = &cobra.Command{
+	Use:   "check-activity <class>",
+	Short: "Show when each student last pushed code",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := formatFlag(cmd)
+		if err != nil {
+			return err
+		}
+		activity, err := classActivity(args[0], true)
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case formatText:
+			fmt.Print(activityText(args[0], activity))
+			return nil
+		case formatJSON:
+			if activity == nil {
+				activity = []studentActivity{}
+			}
+			return writeJSONTo(os.Stdout, activity)
+		}
+		return activityTable(activity).write(os.Stdout, format)
+	},
+}
+
+var weekHistoryCmd = &cobra.Command{
+	Use:   "week-history <class>",
+	Short: "Show which days each student pushed this school week",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := formatFlag(cmd)
+		if err != nil {
+			return err
+		}
+		activity, err := classActivity(args[0], true)
+		if err != nil {
+			return err
+		}
+
+		days := gridDays()
+		switch format {
+		case formatText:
+			fmt.Print(weekText(activity, days))
+			return nil
+		case formatJSON:
+			return writeJSONTo(os.Stdout, weekRows(activity, days))
+		}
+		return weekTable(activity, days).write(os.Stdout, format)
+	},
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report <class> [username]",
+	Short: "Save a Markdown or HTML progress report for a class or student",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		dir, _ := cmd.Flags().GetString("out")
+		fromFlag, _ := cmd.Flags().GetString("from")
+		toFlag, _ := cmd.Flags().GetString("to")
+
+		to := time.Now()
+		if toFlag != "" {
+			t, err := time.ParseInLocation("2006-01-02", toFlag, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid --to date: %v", err)
+			}
+			to = t
+		}
+		from := to.AddDate(0, 0, -defaultReportDays)
+		if fromFlag != "" {
+			t, err := time.ParseInLocation("2006-01-02", fromFlag, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid --from date: %v", err)
+			}
+			from = t
+		}
+		if from.After(to) {
+			return fmt.Errorf("--from is after --to")
+		}
+
+		var username string
+		if len(args) == 2 {
+			username = args[1]
+		}
+		return printResult(generateReport(args[0], username, from, to, format, dir))
+	},
+}
+
+var addNoteCmd = &cobra.Command{
+	Use:   "add-note <username> <note>...",
+	Short: "Add a teacher note about a student",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(addNote(args[0], strings.Join(args[1:], " ")))
+	},
+}
+
+var listNotesCmd = &cobra.Command{
+	Use:   "list-notes <username>",
+	Short: "List the teacher notes about a student",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printResult(listNotes(args[0]))
+	},
+}
+
+var openCmd = &cobra.Command{
+	Use:       "open <username> [repo|pages|editor]",
+	Short:     "Open a student's GitHub repo, Pages site or local clone",
+	Args:      cobra.RangeArgs(1, 2),
+	ValidArgs: []string{"repo", "pages", "editor"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username, target := args[0], "repo"
+		if len(args) == 2 {
+			target = args[1]
+		}
+		printOnly, _ := cmd.Flags().GetBool("print")
+		repo, err := studentRepoPattern(username)
+		if className, _ := cmd.Flags().GetString("class"); className != "" {
+			repo, err = classRepoPattern(className)
+		}
+
+		var url string
+		switch target {
+		case "repo", "pages":
+			if err != nil {
+				return err
+			}
+			url = repo.url(username)
+			if target == "pages" {
+				url = repo.pagesURL(username)
+			}
+		case "editor":
+			editor, err := editorCommand(username)
+			if err != nil {
+				return err
+			}
+			if printOnly {
+				fmt.Println(username)
+				return nil
+			}
+			return editor.Run()
+		default:
+			return fmt.Errorf("unknown target %q (want repo, pages or editor)", target)
+		}
+
+		if printOnly {
+			fmt.Println(url)
+			return nil
+		}
+		return openURL(url)
+	},
+}
+
+var browseCmd = &cobra.Command{
+	Use:   "browse <class> [username]",
+	Short: "Browse students' cloned repositories",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var username string
+		if len(args) == 2 {
+			username = args[1]
+		}
+		assignmentName, _ := cmd.Flags().GetString("assignment")
+		return showCodeBrowser(args[0], username, assignmentName)
+	},
+}
+
+var reviewCmd = &cobra.Command{
+	Use:   "review <class> <username> <path> <line[-end]> <note...>",
+	Short: "Attach a review note to lines of a file in a student's clone",
+	Args:  cobra.MinimumNArgs(5),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireStudent(args[0], args[1]); err != nil {
+			return err
+		}
+		assignmentName, _ := cmd.Flags().GetString("assignment")
+		a, err := findReviewAssignment(args[0], assignmentName)
+		if err != nil {
+			return err
+		}
+		n, err := addReviewNote(args[1], a, args[2], args[3], strings.Join(args[4:], " "))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Added review note %d on %s\n", n.ID, n.location())
+		return nil
+	},
+}
+
+var reviewNotesCmd = &cobra.Command{
+	Use:   "review-notes <class> <username>",
+	Short: "List a student's review notes",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := formatFlag(cmd)
+		if err != nil {
+			return err
+		}
+		assignmentName, _ := cmd.Flags().GetString("assignment")
+		notes, err := listReviewNotes(args[0], args[1], assignmentName)
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case formatText, formatPlain:
+			fmt.Print(reviewNotesText(args[1], notes))
+			return nil
+		case formatJSON:
+			if notes == nil {
+				notes = []reviewNote{}
+			}
+			return writeJSONTo(os.Stdout, notes)
+		}
+		return reviewTable(notes).write(os.Stdout, format)
+	},
+}
+
+var deleteReviewCmd = &cobra.Command{
+	Use:   "delete-review <id>",
+	Short: "Delete a review note",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid note id %q", args[0])
+		}
+		return printResult(deleteReviewNote(id))
+	},
+}
+
+// formatFlag reads and validates the --format flag of a report command.
+func formatFlag(cmd *cobra.Command) (string, error) {
+	format, _ := cmd.Flags().GetString("format")
+	return resolveFormat(format)
+}
+
+// printResult prints the output of an action that returns a report.
+func printResult(out string, err error) error {
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
+
+// confirm asks a yes/no question on stdin, defaulting to no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{listStudentsCmd, checkActivityCmd, weekHistoryCmd, submissionsCmd, checkResultsCmd, lintCmd, gradebookCmd, sendFeedbackCmd, feedbackIssuesCmd, reviewNotesCmd, healthCmd, pullCmd} {
+		cmd.Flags().String("format", "", "output format: text, plain, json, csv, tsv or markdown (default text on a terminal, plain otherwise)")
+	}
+	moveStudentCmd.Flags().Bool("copy", false, "keep the students in the original class as well")
+	purgeCmd.Flags().BoolP("yes", "y", false, "skip the confirmation prompt")
+	trendsCmd.Flags().Int("weeks", defaultTrendWeeks, "number of weeks to include")
+	daemonCmd.Flags().Duration("interval", defaultSyncInterval, "time between syncs")
+	daemonCmd.Flags().Bool("once", false, "run a single sync and exit (for cron)")
+	daemonCmd.Flags().Bool("allow-reset", false, "apply the reset and reclone pull policies instead of skipping (local work in clones is lost)")
+	serveCmd.Flags().String("addr", "localhost:8080", "address to listen on")
+	serveCmd.Flags().Duration("refresh", time.Minute, "how often the page reloads")
+	diffCmd.Flags().Bool("stat", false, "print per-student file stats instead of opening the viewer")
+	similarityCmd.Flags().Float64("threshold", defaultSimilarityThreshold, "share of a submission (0-1) that must match another to be reported")
+	runChecksCmd.Flags().Duration("timeout", defaultSandboxLimits.Timeout, "stop a student's check after this long")
+	runChecksCmd.Flags().Duration("cpu", defaultSandboxLimits.CPU, "CPU time limit per process (Linux)")
+	runChecksCmd.Flags().Int64("memory", defaultSandboxLimits.MemoryMB, "memory limit per process in MB (Linux)")
+	healthCmd.Flags().Bool("refresh", false, fmt.Sprintf("ask GitHub again even if the last check is under %s old", repoHealthMaxAge))
+	pullCmd.Flags().String("policy", "", "override the class's pull policy for this run: skip, stash, reset or reclone")
+	pullCmd.Flags().Bool("dry-run", false, "fetch and report each clone's state without changing it")
+	sendFeedbackCmd.Flags().Bool("dry-run", false, "print the issues that would be posted without calling GitHub")
+	runChecksCmd.Flags().Bool("allow-network", false, "let checks use the network, e.g. for npm install")
+	for _, cmd := range []*cobra.Command{browseCmd, reviewCmd, reviewNotesCmd} {
+		cmd.Flags().String("assignment", "", "assignment the review notes belong to")
+	}
+	for _, cmd := range []*cobra.Command{cloneCmd, pullCmd} {
+		cmd.Flags().String("assignment", "", "use this assignment's clone options instead of the class's")
+	}
+	cloneOptionsCmd.Flags().Int("depth", 0, "clone only the last N commits (0 for full history)")
+	cloneOptionsCmd.Flags().String("filter", "", "partial clone filter, e.g. blob:none (\"\" to clear)")
+	cloneOptionsCmd.Flags().String("sparse", "", "comma-separated folders to check out (\"\" for all)")
+	cloneOptionsCmd.Flags().Bool("single-branch", false, "fetch only the default branch")
+	openCmd.Flags().Bool("print", false, "print the URL (or clone path) instead of opening it")
+	openCmd.Flags().String("class", "", "use this class's repo pattern (needed when the student's classes differ)")
+	reportCmd.Flags().String("format", formatMarkdown, "report format: markdown or html")
+	reportCmd.Flags().String("out", "reports", "directory to save the report in")
+	reportCmd.Flags().String("from", "", fmt.Sprintf("first day of the report, YYYY-MM-DD (default %d days before --to)", defaultReportDays))
+	reportCmd.Flags().String("to", "", "last day of the report, YYYY-MM-DD (default today)")
+	serveCmd.Flags().String("token", os.Getenv("SCV_API_TOKEN"), "bearer token for the JSON API (default $SCV_API_TOKEN)")
+
+	rootCmd.AddCommand(moveStudentCmd)
+	rootCmd.AddCommand(archiveClassCmd)
+	rootCmd.AddCommand(restoreClassCmd)
+	rootCmd.AddCommand(archiveStudentCmd)
+	rootCmd.AddCommand(restoreStudentCmd)
+	rootCmd.AddCommand(purgeCmd)
+	rootCmd.AddCommand(trendsCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(syncStatusCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(addAssignmentCmd)
+	rootCmd.AddCommand(listAssignmentsCmd)
+	rootCmd.AddCommand(setStarterCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(setDueCmd)
+	rootCmd.AddCommand(setAssignedCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(submissionsCmd)
+	rootCmd.AddCommand(setCheckCmd)
+	rootCmd.AddCommand(runChecksCmd)
+	rootCmd.AddCommand(checkResultsCmd)
+	rootCmd.AddCommand(addCriterionCmd)
+	rootCmd.AddCommand(removeCriterionCmd)
+	rootCmd.AddCommand(rubricCmd)
+	rootCmd.AddCommand(gradeCmd)
+	rootCmd.AddCommand(gradeCommentCmd)
+	rootCmd.AddCommand(gradebookCmd)
+	rootCmd.AddCommand(sendFeedbackCmd)
+	rootCmd.AddCommand(feedbackIssuesCmd)
+	rootCmd.AddCommand(healthCmd)
+	rootCmd.AddCommand(cloneCmd)
+	rootCmd.AddCommand(cloneOptionsCmd)
+	rootCmd.AddCommand(setPullPolicyCmd)
+	rootCmd.AddCommand(setRepoPatternCmd)
+	rootCmd.AddCommand(pullCmd)
+	rootCmd.AddCommand(lintCmd)
+	rootCmd.AddCommand(similarityCmd)
+	rootCmd.AddCommand(listStudentsCmd)
+	rootCmd.AddCommand(checkActivityCmd)
+	rootCmd.AddCommand(weekHistoryCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(addNoteCmd)
+	rootCmd.AddCommand(listNotesCmd)
+	rootCmd.AddCommand(openCmd)
+	rootCmd.AddCommand(browseCmd)
+	rootCmd.AddCommand(reviewCmd)
+	rootCmd.AddCommand(reviewNotesCmd)
+	rootCmd.AddCommand(deleteReviewCmd)
+}
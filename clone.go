@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// cloneFilterPattern is the partial clone filters worth offering: skip file
+// contents until they are checked out, skip big files, or skip trees.
+var cloneFilterPattern = regexp.MustCompile(`^(blob:none|blob:limit=[0-9]+[kmg]?|tree:[0-9]+)$`)
+
+// cloneOptions make student clones smaller. Depth and Filter only take
+// effect when a repository is cloned (or re-cloned); Sparse and SingleBranch
+// are also applied to existing clones whenever they are pulled.
+type cloneOptions struct {
+	Depth        int      `json:"depth,omitempty"`
+	Filter       string   `json:"filter,omitempty"`
+	Sparse       []string `json:"sparse,omitempty"` // folders to check out
+	SingleBranch bool     `json:"single_branch,omitempty"`
+}
+
+// full reports whether no options are set.
+func (o cloneOptions) full() bool {
+	return o.Depth == 0 && o.Filter == "" && len(o.Sparse) == 0 && !o.SingleBranch
+}
+
+func (o cloneOptions) String() string {
+	if o.full() {
+		return "full clone"
+	}
+	var parts []string
+	if o.Depth > 0 {
+		parts = append(parts, fmt.Sprintf("depth %d", o.Depth))
+	}
+	if o.Filter != "" {
+		parts = append(parts, "filter "+o.Filter)
+	}
+	if len(o.Sparse) > 0 {
+		parts = append(parts, "sparse "+strings.Join(o.Sparse, ", "))
+	}
+	if o.SingleBranch {
+		parts = append(parts, "single branch")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// override returns o with the fields an assignment sets replaced.
+func (o cloneOptions) override(a cloneOptions) cloneOptions {
+	if a.Depth > 0 {
+		o.Depth = a.Depth
+	}
+	if a.Filter != "" {
+		o.Filter = a.Filter
+	}
+	if len(a.Sparse) > 0 {
+		o.Sparse = a.Sparse
+	}
+	o.SingleBranch = o.SingleBranch || a.SingleBranch
+	return o
+}
+
+func (o cloneOptions) validate() error {
+	if o.Depth < 0 {
+		return fmt.Errorf("depth must be 0 (full history) or more")
+	}
+	if o.Filter != "" && !cloneFilterPattern.MatchString(o.Filter) {
+		return fmt.Errorf("unsupported filter %q (want blob:none, blob:limit=<size> or tree:<depth>)", o.Filter)
+	}
+	for _, path := range o.Sparse {
+		if !filepath.IsLocal(path) {
+			return fmt.Errorf("sparse path %s is outside the repository", path)
+		}
+	}
+	return nil
+}
+
+// parseSparsePaths reads a comma-separated list of folders.
+func parseSparsePaths(s string) []string {
+	var paths []string
+	for _, path := range strings.Split(s, ",") {
+		if path = strings.Trim(filepath.ToSlash(strings.TrimSpace(path)), "/"); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// scanCloneOptions reads the clone_* columns, which classes and assignments
+// share.
+func scanCloneOptions(row rowScanner) (cloneOptions, error) {
+	var o cloneOptions
+	var sparse string
+	if err := row.Scan(&o.Depth, &o.Filter, &sparse, &o.SingleBranch); err != nil {
+		return o, err
+	}
+	if sparse != "" {
+		o.Sparse = strings.Split(sparse, "\n")
+	}
+	return o, nil
+}
+
+const cloneOptionColumns = "clone_depth, clone_filter, clone_sparse, clone_single_branch"
+
+// classCloneOptions returns the options set on a class, or on one of its
+// assignments when assignmentName isn't empty.
+func classCloneOptions(className, assignmentName string) (cloneOptions, error) {
+	o, err := scanCloneOptions(db.QueryRow("SELECT "+cloneOptionColumns+" FROM classes WHERE name = ? AND archived = 0", className))
+	if err != nil {
+		return o, fmt.Errorf("class not found: %s", className)
+	}
+	if assignmentName == "" {
+		return o, nil
+	}
+	assignmentOptions, err := assignmentCloneOptions(className, assignmentName)
+	if err != nil {
+		return o, err
+	}
+	return o.override(assignmentOptions), nil
+}
+
+// assignmentCloneOptions returns only what an assignment sets itself.
+func assignmentCloneOptions(className, assignmentName string) (cloneOptions, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return cloneOptions{}, err
+	}
+	return scanCloneOptions(db.QueryRow("SELECT "+cloneOptionColumns+" FROM assignments WHERE id = ?", a.ID))
+}
+
+// setCloneOptions stores the options of a class, or of one of its
+// assignments.
+func setCloneOptions(className, assignmentName string, o cloneOptions) (string, error) {
+	if err := o.validate(); err != nil {
+		return "", err
+	}
+	query := "UPDATE classes SET clone_depth = ?, clone_filter = ?, clone_sparse = ?, clone_single_branch = ? WHERE name = ? AND archived = 0"
+	args := []any{o.Depth, o.Filter, strings.Join(o.Sparse, "\n"), o.SingleBranch, className}
+	target := className
+	if assignmentName != "" {
+		a, err := findAssignment(className, assignmentName)
+		if err != nil {
+			return "", err
+		}
+		query = "UPDATE assignments SET clone_depth = ?, clone_filter = ?, clone_sparse = ?, clone_single_branch = ? WHERE id = ?"
+		args[len(args)-1] = a.ID
+		target = "assignment " + a.Name
+	}
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to set clone options: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "", fmt.Errorf("class not found: %s", className)
+	}
+	return fmt.Sprintf("Clone options for %s: %s\n", target, o), nil
+}
+
+// cloneRepository clones a student's repository with the given options.
+func cloneRepository(username string, repo repoPattern, o cloneOptions) error {
+	return vcs.Clone(repo.url(username), username, o)
+}
+
+// errShallowHistory is returned where a shallow clone's history may not
+// reach back far enough to give the right answer.
+var errShallowHistory = errors.New("history unavailable in a shallow clone (scv snapshot deepens it, or clear the clone depth)")
+
+// shallowSinceKey is the git config key where Deepen records how far back
+// a shallow clone's history goes.
+const shallowSinceKey = "scv.shallowSince"
+
+func isShallow(dir string) bool {
+	shallow, _, _ := vcs.Shallow(dir)
+	return shallow
+}
+
+// hasHistorySince reports whether a clone has every commit made after
+// since. A full clone does; a shallow one only once deepenSince has fetched
+// back that far. A zero since asks for the whole history.
+func hasHistorySince(dir string, since time.Time) bool {
+	shallow, deepened, err := vcs.Shallow(dir)
+	switch {
+	case err != nil:
+		return false
+	case !shallow:
+		return true
+	}
+	return !since.IsZero() && !deepened.IsZero() && !deepened.After(since)
+}
+
+// deepenSince fetches what a shallow clone is missing from after since, or
+// its whole history when since is zero.
+func deepenSince(dir string, since time.Time) error {
+	if hasHistorySince(dir, since) {
+		return nil
+	}
+	return vcs.Deepen(dir, since)
+}
+
+// cloneClass clones every student in a class who isn't cloned yet, using
+// the class's clone options or an assignment's.
+func cloneClass(className, assignmentName string) (string, error) {
+	o, err := classCloneOptions(className, assignmentName)
+	if err != nil {
+		return "", err
+	}
+	repo, err := classRepoPattern(className)
+	if err != nil {
+		return "", err
+	}
+	usernames, err := classStudents(className)
+	if err != nil {
+		return "", fmt.Errorf("failed to query students: %v", err)
+	}
+
+	var sb strings.Builder
+	if !o.full() {
+		sb.WriteString(fmt.Sprintf("Cloning with %s\n", o))
+	}
+	for _, username := range usernames {
+		if isCloned(username) {
+			sb.WriteString(fmt.Sprintf("Already cloned: %s\n", username))
+			continue
+		}
+		if err := cloneRepository(username, repo, o); err != nil {
+			sb.WriteString(fmt.Sprintf("Failed to clone repository for %s: %v\n", username, err))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("Cloned repository for: %s\n", username))
+	}
+	return sb.String(), nil
+}
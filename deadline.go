@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// deadlineLayout is how deadlines are entered and shown, in local time.
+const deadlineLayout = "2006-01-02 15:04"
+
+// parseDeadline accepts RFC 3339, "YYYY-MM-DD HH:MM" or a bare date, which
+// means the end of that day. The last two are in local time.
+func parseDeadline(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(deadlineLayout, s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deadline %q (want YYYY-MM-DD, \"YYYY-MM-DD HH:MM\" or RFC 3339)", s)
+	}
+	return t.Add(24*time.Hour - time.Second), nil
+}
+
+// parseAssigned is parseDeadline for when work starts, so a bare date
+// means the start of that day.
+func parseAssigned(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return parseDeadline(s)
+}
+
+// deadlineSnapshot is the commit a student is graded on: the last one
+// committed before the deadline. SHA is empty if there was none.
+type deadlineSnapshot struct {
+	Username    string
+	SHA         string
+	CommittedAt time.Time
+	LateCommits int // commits on the default branch after SHA
+	Error       string
+}
+
+var unsafeRefChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// deadlineTag is the local tag marking a student's on-time work. The
+// assignment ID keeps names that only differ in punctuation apart.
+func deadlineTag(a assignment) string {
+	tag := fmt.Sprintf("deadline/%d", a.ID)
+	if name := strings.Trim(unsafeRefChars.ReplaceAllString(a.Name, "-"), "-."); name != "" {
+		tag += "-" + name
+	}
+	return tag
+}
+
+// remoteHead is the ref for the student's default branch as last fetched,
+// falling back to the local HEAD for clones without one.
+func remoteHead(username string) string {
+	if _, err := vcs.Resolve(username, "refs/remotes/origin/HEAD"); err == nil {
+		return "refs/remotes/origin/HEAD"
+	}
+	return "HEAD"
+}
+
+// takeSnapshot fetches a student's clone, finds the last commit before the
+// deadline and tags it. Commit dates come from the student's machine.
+func takeSnapshot(username string, a assignment) deadlineSnapshot {
+	snap := deadlineSnapshot{Username: username}
+	fail := func(err error) deadlineSnapshot {
+		snap.Error = err.Error()
+		return snap
+	}
+
+	if !isCloned(username) {
+		return fail(fmt.Errorf("not cloned"))
+	}
+	if err := vcs.Fetch(username); err != nil {
+		return fail(err)
+	}
+	// A shallow clone may not reach back to the last commit before the
+	// deadline.
+	if err := deepenSince(username, a.AssignedAt); err != nil {
+		return fail(err)
+	}
+
+	head := remoteHead(username)
+	commits, err := vcs.Commits(username, head, "")
+	if err != nil {
+		return fail(err)
+	}
+	for _, c := range commits {
+		// Work from before the assignment was set isn't a submission.
+		if !c.Committed.After(a.DueAt) && !c.Committed.Before(a.AssignedAt) {
+			snap.SHA, snap.CommittedAt = c.SHA, c.Committed
+			break
+		}
+	}
+
+	// An empty SHA drops a tag left by an earlier snapshot with a later
+	// deadline.
+	if err := vcs.Tag(username, deadlineTag(a), snap.SHA); err != nil {
+		return fail(err)
+	}
+	if snap.SHA == "" {
+		snap.LateCommits = len(commits)
+		return snap
+	}
+	late, err := vcs.Commits(username, head, snap.SHA)
+	if err != nil {
+		return fail(err)
+	}
+	snap.LateCommits = len(late)
+	return snap
+}
+
+func saveDeadlineSnapshot(a assignment, snap deadlineSnapshot) error {
+	var committed any
+	if !snap.CommittedAt.IsZero() {
+		committed = snap.CommittedAt.UTC()
+	}
+	var snapErr any
+	if snap.Error != "" {
+		snapErr = snap.Error
+	}
+	_, err := db.Exec(`
+		INSERT INTO deadline_snapshots (assignment_id, username, taken_at, sha, committed_at, late_commits, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(assignment_id, username) DO UPDATE SET
+			taken_at = excluded.taken_at, sha = excluded.sha, committed_at = excluded.committed_at,
+			late_commits = excluded.late_commits, error = excluded.error`,
+		a.ID, snap.Username, time.Now().UTC(), snap.SHA, committed, snap.LateCommits, snapErr)
+	return err
+}
+
+// snapshotDeadline records every student's on-time commit for an
+// assignment whose deadline has passed.
+func snapshotDeadline(className, assignmentName string) (string, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return "", err
+	}
+	if a.DueAt.IsZero() {
+		return "", fmt.Errorf("assignment %s has no deadline; set one with scv set-due", a.Name)
+	}
+	if time.Now().Before(a.DueAt) {
+		return "", fmt.Errorf("assignment %s is not due until %s", a.Name, a.DueAt.Local().Format(deadlineLayout))
+	}
+	usernames, err := classStudents(className)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Deadline snapshot for %s (due %s), tagged %s:\n",
+		a.Name, a.DueAt.Local().Format(deadlineLayout), deadlineTag(a)))
+	sb.WriteString("----------------------------------------\n")
+	for _, username := range usernames {
+		snap := takeSnapshot(username, a)
+		if err := saveDeadlineSnapshot(a, snap); err != nil {
+			return "", fmt.Errorf("failed to save snapshot: %v", err)
+		}
+
+		switch {
+		case snap.Error != "":
+			sb.WriteString(fmt.Sprintf("%s: error - %s\n", username, snap.Error))
+		case snap.SHA == "":
+			sb.WriteString(fmt.Sprintf("%s: no commits before the deadline", username))
+		default:
+			sb.WriteString(fmt.Sprintf("%s: %.7s committed %s", username, snap.SHA, snap.CommittedAt.Local().Format(deadlineLayout)))
+		}
+		if snap.Error == "" {
+			if snap.LateCommits > 0 {
+				sb.WriteString(fmt.Sprintf(" (%d late commits)", snap.LateCommits))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// starterDir is the bare clone of an assignment's starter repository,
+// shared by every student's diff.
+func starterDir(a assignment) string {
+	return filepath.Join(".scv", "starters", strconv.Itoa(a.ID))
+}
+
+// git runs a git command and returns its trimmed output, including stderr
+// in the error so failures are readable.
+func git(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		sub := args[0]
+		if sub == "-C" && len(args) > 2 {
+			sub = args[2]
+		}
+		return "", fmt.Errorf("git %s failed: %v: %s", sub, err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// syncStarter clones or updates the starter cache and returns the commit
+// the assignment's starter ref points at.
+func syncStarter(a assignment) (string, error) {
+	if a.StarterRepo == "" {
+		return "", fmt.Errorf("assignment %s has no starter repository; set one with scv set-starter", a.Name)
+	}
+
+	dir := starterDir(a)
+	if _, err := os.Stat(dir); err != nil {
+		if _, err := git("clone", "--quiet", "--bare", a.StarterRepo, dir); err != nil {
+			return "", err
+		}
+	} else if _, err := git("-C", dir, "fetch", "--quiet", "--prune", a.StarterRepo,
+		"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"); err != nil {
+		return "", err
+	}
+
+	ref := a.StarterRef
+	if ref == "" {
+		ref = "HEAD"
+	}
+	sha, err := git("-C", dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("starter ref %s not found in %s", ref, a.StarterRepo)
+	}
+	return sha, nil
+}
+
+// fetchStarterInto copies the starter's objects into a student clone so the
+// two can be diffed. Refs land under refs/scv/ to stay out of the way.
+func fetchStarterInto(username string, a assignment) error {
+	abs, err := filepath.Abs(starterDir(a))
+	if err != nil {
+		return err
+	}
+	return vcs.FetchRefs(username, abs, "+refs/heads/*:refs/scv/starter/heads/*", "+refs/tags/*:refs/scv/starter/tags/*")
+}
+
+// fileChange is one file's line counts in a diff.
+type fileChange struct {
+	Path    string
+	Added   int
+	Deleted int
+	Binary  bool
+}
+
+// studentDiff is what a student changed relative to the starter.
+type studentDiff struct {
+	Username string
+	Files    []fileChange
+	Added    int
+	Deleted  int
+	Err      error
+}
+
+func diffStats(username, base string) ([]fileChange, error) {
+	out, err := git("-C", username, "diff", "--numstat", "--no-renames", base, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []fileChange
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		fc := fileChange{Path: fields[2]}
+		if fields[0] == "-" {
+			fc.Binary = true
+		} else {
+			fc.Added, _ = strconv.Atoi(fields[0])
+			fc.Deleted, _ = strconv.Atoi(fields[1])
+		}
+		files = append(files, fc)
+	}
+	return files, nil
+}
+
+func fileDiff(username, base, path string) (string, error) {
+	return git("-C", username, "diff", "--no-renames", base, "HEAD", "--", path)
+}
+
+// diffStudent compares one student's HEAD with the starter commit.
+func diffStudent(username string, a assignment, base string) studentDiff {
+	d := studentDiff{Username: username}
+	if !isCloned(username) {
+		d.Err = fmt.Errorf("not cloned")
+		return d
+	}
+	if d.Err = fetchStarterInto(username, a); d.Err != nil {
+		return d
+	}
+
+	d.Files, d.Err = diffStats(username, base)
+	for _, f := range d.Files {
+		d.Added += f.Added
+		d.Deleted += f.Deleted
+	}
+	return d
+}
+
+// assignmentDiffs diffs every student in a class against the assignment's
+// starter and returns the starter commit used.
+func assignmentDiffs(className, assignmentName string) ([]studentDiff, string, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return nil, "", err
+	}
+	base, err := syncStarter(a)
+	if err != nil {
+		return nil, "", err
+	}
+	usernames, err := classStudents(className)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var diffs []studentDiff
+	for _, username := range usernames {
+		diffs = append(diffs, diffStudent(username, a, base))
+	}
+	return diffs, base, nil
+}
+
+// diffSummary is a plain-text diffstat for every student.
+func diffSummary(className, assignmentName string) (string, error) {
+	diffs, base, err := assignmentDiffs(className, assignmentName)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Changes since starter %.7s for %s in %s:\n", base, assignmentName, className))
+	sb.WriteString("----------------------------------------\n")
+	for _, d := range diffs {
+		if d.Err != nil {
+			sb.WriteString(fmt.Sprintf("%s: error - %v\n", d.Username, d.Err))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s: %d files changed, +%d -%d\n", d.Username, len(d.Files), d.Added, d.Deleted))
+		for _, f := range d.Files {
+			if f.Binary {
+				sb.WriteString(fmt.Sprintf("    %s (binary)\n", f.Path))
+			} else {
+				sb.WriteString(fmt.Sprintf("    %s +%d -%d\n", f.Path, f.Added, f.Deleted))
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+// colorDiff adds tview colour tags to unified diff output.
+func colorDiff(diff string) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(diff, "\n") {
+		escaped := tview.Escape(line)
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"),
+			strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "):
+			sb.WriteString("[gray]" + escaped + "[-]\n")
+		case strings.HasPrefix(line, "@@"):
+			sb.WriteString("[aqua]" + escaped + "[-]\n")
+		case strings.HasPrefix(line, "+"):
+			sb.WriteString("[green]" + escaped + "[-]\n")
+		case strings.HasPrefix(line, "-"):
+			sb.WriteString("[red]" + escaped + "[-]\n")
+		default:
+			sb.WriteString(escaped + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// showDiffViewer runs a tview screen listing each student's changed files
+// next to the selected file's diff.
+func showDiffViewer(className, assignmentName string) error {
+	diffs, base, err := assignmentDiffs(className, assignmentName)
+	if err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		return fmt.Errorf("no students in class: %s", className)
+	}
+
+	app := tview.NewApplication()
+	header := tview.NewTextView().SetDynamicColors(true)
+	files := tview.NewList().ShowSecondaryText(false).SetHighlightFullLine(true)
+	view := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	files.SetBorder(true).SetTitle("Changed files")
+	view.SetBorder(true).SetTitle("Diff")
+
+	index := 0
+	load := func(i int) {
+		index = i
+		d := diffs[i]
+		header.SetText(fmt.Sprintf("[yellow]%s[white]  (%d/%d)  %d files, [green]+%d[white] [red]-%d[white]  vs starter %.7s   [gray]n/p: next/previous student · Tab: switch pane · Esc: back",
+			d.Username, i+1, len(diffs), len(d.Files), d.Added, d.Deleted, base))
+
+		files.Clear()
+		view.Clear()
+		if d.Err != nil {
+			view.SetText(fmt.Sprintf("[red]%s", tview.Escape(d.Err.Error())))
+			return
+		}
+		if len(d.Files) == 0 {
+			view.SetText("[gray]No changes from the starter.")
+			return
+		}
+		for _, f := range d.Files {
+			label := fmt.Sprintf("[green]+%-4d[red]-%-4d[-] %s", f.Added, f.Deleted, tview.Escape(f.Path))
+			if f.Binary {
+				label = fmt.Sprintf("[gray]binary     [-] %s", tview.Escape(f.Path))
+			}
+			files.AddItem(label, "", 0, nil)
+		}
+		files.SetCurrentItem(0)
+	}
+
+	files.SetChangedFunc(func(i int, _ string, _ string, _ rune) {
+		d := diffs[index]
+		if i < 0 || i >= len(d.Files) {
+			return
+		}
+		out, err := fileDiff(d.Username, base, d.Files[i].Path)
+		if err != nil {
+			view.SetText(fmt.Sprintf("[red]%s", tview.Escape(err.Error())))
+			return
+		}
+		view.SetText(colorDiff(out)).ScrollToBeginning()
+	})
+
+	body := tview.NewFlex().
+		AddItem(files, 0, 1, true).
+		AddItem(view, 0, 3, false)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(header, 1, 0, false).
+		AddItem(body, 0, 1, true)
+
+	layout.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape:
+			app.Stop()
+			return nil
+		case event.Key() == tcell.KeyTab:
+			if files.HasFocus() {
+				app.SetFocus(view)
+			} else {
+				app.SetFocus(files)
+			}
+			return nil
+		case event.Rune() == 'n':
+			load((index + 1) % len(diffs))
+			return nil
+		case event.Rune() == 'p':
+			load((index + len(diffs) - 1) % len(diffs))
+			return nil
+		}
+		return event
+	})
+
+	load(0)
+	return app.SetRoot(layout, true).Run()
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Outcomes of sending one student's feedback.
+const (
+	feedbackCreated   = "created"
+	feedbackUpdated   = "updated"
+	feedbackUnchanged = "unchanged" // the issue already has this text
+	feedbackNoGrade   = "not_graded"
+	feedbackError     = "error"
+)
+
+// feedbackIssue is the issue a student's feedback was posted to.
+type feedbackIssue struct {
+	Username string    `json:"username"`
+	Number   int       `json:"number"`
+	URL      string    `json:"url"`
+	Body     string    `json:"-"`
+	PostedAt time.Time `json:"posted_at"`
+}
+
+// feedbackResult is what happened to one student's feedback.
+type feedbackResult struct {
+	Username string `json:"username"`
+	Action   string `json:"action"`
+	URL      string `json:"url,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// feedbackTitle is the title of the issue feedback is posted as.
+func feedbackTitle(a assignment) string {
+	return "Feedback: " + a.Name
+}
+
+// feedbackBody renders a student's grade and review notes as the Markdown
+// posted to their repository.
+func feedbackBody(a assignment, criteria []criterion, g studentGrade, notes []reviewNote, repo repoPattern) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("## Feedback on %s\n\n", a.Name))
+	if g.Comment != "" {
+		sb.WriteString(g.Comment + "\n\n")
+	}
+
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+	sb.WriteString("| Criterion | Score | Comment |\n| --- | --- | --- |\n")
+	for _, c := range criteria {
+		score := "-"
+		if s, ok := g.Scores[c.ID]; ok {
+			score = formatPoints(s)
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s / %s | %s |\n", escape.Replace(c.Name), score, formatPoints(c.Points), escape.Replace(g.Comments[c.ID])))
+	}
+	sb.WriteString(fmt.Sprintf("| **Total** | **%s / %s** | |\n", formatPoints(g.total()), formatPoints(rubricTotal(criteria))))
+
+	if len(notes) > 0 {
+		sb.WriteString("\n### Notes on your code\n\n")
+		for _, n := range notes {
+			sb.WriteString(fmt.Sprintf("- [`%s` line %s](%s): %s\n", n.Path, n.Lines(), n.permalink(repo), n.Body))
+		}
+	}
+	return sb.String()
+}
+
+func loadFeedbackIssue(a assignment, username string) (*feedbackIssue, error) {
+	issue := feedbackIssue{Username: username}
+	err := db.QueryRow("SELECT issue_number, url, body, posted_at FROM feedback_issues WHERE assignment_id = ? AND username = ?", a.ID, username).
+		Scan(&issue.Number, &issue.URL, &issue.Body, &issue.PostedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+func saveFeedbackIssue(a assignment, issue feedbackIssue) error {
+	_, err := db.Exec(`
+		INSERT INTO feedback_issues (assignment_id, username, issue_number, url, body, posted_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(assignment_id, username) DO UPDATE SET
+			issue_number = excluded.issue_number, url = excluded.url, body = excluded.body, posted_at = excluded.posted_at`,
+		a.ID, issue.Username, issue.Number, issue.URL, issue.Body, issue.PostedAt.UTC())
+	return err
+}
+
+// postFeedback creates the feedback issue on a student's repository, or
+// edits the one posted before. It returns the action taken.
+func postFeedback(a assignment, username string, repo repoPattern, body string, previous *feedbackIssue) (feedbackIssue, string, error) {
+	issues := fmt.Sprintf("%s/repos/%s/%s/issues", githubAPI, username, repo.name(username))
+	var resp struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+
+	action := feedbackCreated
+	err := errGithubNotFound
+	if previous != nil {
+		action = feedbackUpdated
+		err = githubJSON("PATCH", fmt.Sprintf("%s/%d", issues, previous.Number), map[string]string{"body": body}, &resp)
+	}
+	if errors.Is(err, errGithubNotFound) {
+		// Never posted, or the old issue was deleted: open a new one.
+		action = feedbackCreated
+		err = githubJSON("POST", issues, map[string]string{"title": feedbackTitle(a), "body": body}, &resp)
+	}
+	if errors.Is(err, errGithubNotFound) {
+		return feedbackIssue{}, "", fmt.Errorf("repository %s/%s not found or issues are disabled", username, repo.name(username))
+	}
+	if err != nil {
+		return feedbackIssue{}, "", err
+	}
+	return feedbackIssue{Username: username, Number: resp.Number, URL: resp.HTMLURL, Body: body, PostedAt: time.Now()}, action, nil
+}
+
+// sendFeedback posts each graded student's feedback as an issue on their
+// repository. With only set, just those students are sent. A dry run
+// prints what would be posted without calling GitHub.
+func sendFeedback(className, assignmentName string, only []string, dryRun bool) ([]feedbackResult, string, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return nil, "", err
+	}
+	criteria, err := rubric(a)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(criteria) == 0 {
+		return nil, "", fmt.Errorf("assignment %s has no rubric; add criteria with scv add-criterion", a.Name)
+	}
+	repo, err := classRepoPattern(className)
+	if err != nil {
+		return nil, "", err
+	}
+	grades, err := assignmentGrades(a)
+	if err != nil {
+		return nil, "", err
+	}
+	usernames, err := classStudents(className)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, username := range only {
+		if err := requireStudent(className, username); err != nil {
+			return nil, "", err
+		}
+	}
+	if len(only) > 0 {
+		usernames = only
+	}
+	if !dryRun && os.Getenv("GITHUB_TOKEN") == "" {
+		return nil, "", fmt.Errorf("GITHUB_TOKEN environment variable not set")
+	}
+
+	var preview strings.Builder
+	var results []feedbackResult
+	for _, username := range usernames {
+		result := feedbackResult{Username: username}
+		g, ok := grades[username]
+		if !ok {
+			result.Action = feedbackNoGrade
+			results = append(results, result)
+			continue
+		}
+		notes, err := reviewNotes(reviewFilter{Username: username, AssignmentID: a.ID})
+		if err != nil {
+			return nil, "", err
+		}
+		body := feedbackBody(a, criteria, *g, notes, repo)
+		previous, err := loadFeedbackIssue(a, username)
+		if err != nil {
+			return nil, "", err
+		}
+
+		switch {
+		case previous != nil && previous.Body == body:
+			result.Action, result.URL = feedbackUnchanged, previous.URL
+		case dryRun:
+			result.Action = feedbackCreated
+			if previous != nil {
+				result.Action, result.URL = feedbackUpdated, previous.URL
+			}
+			preview.WriteString(fmt.Sprintf("--- %s/%s: %s %q\n%s\n", username, repo.name(username), result.Action, feedbackTitle(a), body))
+		default:
+			issue, action, err := postFeedback(a, username, repo, body, previous)
+			if err != nil {
+				result.Action, result.Error = feedbackError, err.Error()
+				break
+			}
+			if err := saveFeedbackIssue(a, issue); err != nil {
+				return nil, "", fmt.Errorf("failed to record issue: %v", err)
+			}
+			result.Action, result.URL = action, issue.URL
+		}
+		results = append(results, result)
+	}
+	return results, preview.String(), nil
+}
+
+func feedbackTable(results []feedbackResult) table {
+	t := table{header: []string{"username", "action", "url", "error"}}
+	for _, r := range results {
+		t.rows = append(t.rows, []string{r.Username, r.Action, r.URL, r.Error})
+	}
+	return t
+}
+
+// feedbackIssues lists the issues feedback has been posted to, in roster
+// order.
+func feedbackIssues(className, assignmentName string) ([]feedbackIssue, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`
+		SELECT f.username, f.issue_number, f.url, f.posted_at
+		FROM feedback_issues f
+		JOIN assignments a ON f.assignment_id = a.id
+		JOIN students s ON s.username = f.username AND s.class_id = a.class_id AND s.archived = 0
+		WHERE f.assignment_id = ?
+		ORDER BY f.username`,
+		a.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var issues []feedbackIssue
+	for rows.Next() {
+		var issue feedbackIssue
+		if err := rows.Scan(&issue.Username, &issue.Number, &issue.URL, &issue.PostedAt); err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
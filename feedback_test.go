@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeIssues is a stand-in for the GitHub issues API, holding issues in
+// memory and counting the requests it gets.
+type fakeIssues struct {
+	mu       sync.Mutex
+	issues   map[int]string // number -> body
+	next     int
+	requests []string
+}
+
+func (f *fakeIssues) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues", func(w http.ResponseWriter, r *http.Request) {
+		var issue struct{ Title, Body string }
+		if err := json.NewDecoder(r.Body).Decode(&issue); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.requests = append(f.requests, "POST")
+		f.next++
+		f.issues[f.next] = issue.Body
+		writeIssue(w, r, f.next)
+	})
+	mux.HandleFunc("PATCH /repos/{owner}/{repo}/issues/{number}", func(w http.ResponseWriter, r *http.Request) {
+		var issue struct{ Body string }
+		if err := json.NewDecoder(r.Body).Decode(&issue); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		number, _ := strconv.Atoi(r.PathValue("number"))
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.requests = append(f.requests, "PATCH "+r.PathValue("number"))
+		if _, ok := f.issues[number]; !ok {
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+			return
+		}
+		f.issues[number] = issue.Body
+		writeIssue(w, r, number)
+	})
+	return mux
+}
+
+func writeIssue(w http.ResponseWriter, r *http.Request, number int) {
+	url := fmt.Sprintf("https://github.com/%s/%s/issues/%d", r.PathValue("owner"), r.PathValue("repo"), number)
+	json.NewEncoder(w).Encode(map[string]any{"number": number, "html_url": url})
+}
+
+// takeRequests returns the requests made since it was last called.
+func (f *fakeIssues) takeRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requests := f.requests
+	f.requests = nil
+	return requests
+}
+
+// newTestDB creates an empty database in a scratch working directory.
+func newTestDB(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := initDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+}
+
+func TestSendFeedback(t *testing.T) {
+	newTestDB(t)
+	fake := &fakeIssues{issues: map[int]string{}}
+	srv := httptest.NewServer(fake.handler())
+	defer srv.Close()
+	oldAPI := githubAPI
+	githubAPI = srv.URL
+	defer func() { githubAPI = oldAPI }()
+	t.Setenv("GITHUB_TOKEN", "test-token")
+
+	if _, err := db.Exec("INSERT INTO classes (name) VALUES (?)", "section1"); err != nil {
+		t.Fatal(err)
+	}
+	checks := []studentCheck{{username: "student1", userExists: true, repoExists: true}, {username: "student2", userExists: true, repoExists: true}}
+	for _, step := range []func() (string, error){
+		func() (string, error) { return addStudents("section1", checks, false) },
+		func() (string, error) { return addAssignment("section1", "portfolio") },
+		func() (string, error) { return addCriterion("section1", "portfolio", "Layout", 10) },
+	} {
+		if _, err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	grade := func(score string) func(t *testing.T) {
+		return func(t *testing.T) {
+			if _, err := gradeStudent("section1", "portfolio", "student1", "Layout", score, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Each step runs after the ones before it, against the same issues.
+	steps := []struct {
+		name         string
+		before       func(t *testing.T)
+		dryRun       bool
+		want         string // student1's action; student2 is never graded
+		wantRequests []string
+		wantIssue    int // the issue student1's feedback should be on
+	}{
+		{"dry run posts nothing", grade("7"), true, feedbackCreated, nil, 0},
+		{"create", nil, false, feedbackCreated, []string{"POST"}, 1},
+		{"unchanged", nil, false, feedbackUnchanged, nil, 1},
+		{"dry run of a change", grade("8"), true, feedbackUpdated, nil, 1},
+		{"update", nil, false, feedbackUpdated, []string{"PATCH 1"}, 1},
+		{"recreate a deleted issue", func(t *testing.T) {
+			fake.mu.Lock()
+			delete(fake.issues, 1)
+			fake.mu.Unlock()
+			grade("9")(t)
+		}, false, feedbackCreated, []string{"PATCH 1", "POST"}, 2},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.before != nil {
+				step.before(t)
+			}
+			results, preview, err := sendFeedback("section1", "portfolio", nil, step.dryRun)
+			if err != nil {
+				t.Fatalf("sendFeedback: %v", err)
+			}
+			if len(results) != 2 || results[1].Action != feedbackNoGrade {
+				t.Fatalf("results = %+v, want student1 and an ungraded student2", results)
+			}
+			if r := results[0]; r.Action != step.want || r.Error != "" {
+				t.Errorf("student1: %s %s, want %s", r.Action, r.Error, step.want)
+			}
+			if got := fake.takeRequests(); strings.Join(got, ", ") != strings.Join(step.wantRequests, ", ") {
+				t.Errorf("requests = %v, want %v", got, step.wantRequests)
+			}
+			if step.dryRun != strings.Contains(preview, `"Feedback: portfolio"`) {
+				t.Errorf("dry run %v, preview %q", step.dryRun, preview)
+			}
+
+			if step.wantIssue == 0 {
+				return
+			}
+			issue, err := loadFeedbackIssue(assignment{ID: 1}, "student1")
+			if err != nil || issue == nil {
+				t.Fatalf("recorded issue: %v, %v", issue, err)
+			}
+			if issue.Number != step.wantIssue || !strings.HasSuffix(issue.URL, fmt.Sprintf("/student1/student1.github.io/issues/%d", step.wantIssue)) {
+				t.Errorf("recorded issue %d at %s, want %d", issue.Number, issue.URL, step.wantIssue)
+			}
+			if !step.dryRun && fake.issues[issue.Number] != issue.Body {
+				t.Errorf("issue %d body on GitHub differs from the recorded body", issue.Number)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Output formats for reports. "text" is the styled output the TUI shows;
+// "plain" is the same information without colours or icons.
+const (
+	formatText     = "text"
+	formatPlain    = "plain"
+	formatJSON     = "json"
+	formatCSV      = "csv"
+	formatTSV      = "tsv"
+	formatMarkdown = "markdown"
+	formatHTML     = "html" // progress report files only
+)
+
+// resolveFormat validates a --format value. When none is given, styled text
+// is used on a terminal and plain text everywhere else (pipes, files).
+func resolveFormat(format string) (string, error) {
+	switch format {
+	case "":
+		if isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+			return formatText, nil
+		}
+		return formatPlain, nil
+	case formatText, formatPlain, formatJSON, formatCSV, formatTSV, formatMarkdown:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q (want text, plain, json, csv, tsv or markdown)", format)
+}
+
+// table is a report in rows and columns, ready for any tabular format.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func (t table) write(w io.Writer, format string) error {
+	switch format {
+	case formatCSV, formatTSV:
+		cw := csv.NewWriter(w)
+		if format == formatTSV {
+			cw.Comma = '\t'
+		}
+		cw.Write(t.header)
+		cw.WriteAll(t.rows)
+		return cw.Error()
+
+	case formatMarkdown:
+		escape := strings.NewReplacer("|", `\|`, "\n", " ")
+		line := func(cells []string) {
+			escaped := make([]string, len(cells))
+			for i, c := range cells {
+				escaped[i] = escape.Replace(c)
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+		}
+		line(t.header)
+		sep := make([]string, len(t.header))
+		for i := range sep {
+			sep[i] = "---"
+		}
+		line(sep)
+		for _, row := range t.rows {
+			line(row)
+		}
+		return nil
+
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// records turns the rows into objects keyed by column name, for JSON
+// output of tables whose columns aren't fixed.
+func (t table) records() []map[string]string {
+	records := make([]map[string]string, 0, len(t.rows))
+	for _, row := range t.rows {
+		record := make(map[string]string, len(row))
+		for i, cell := range row {
+			record[t.header[i]] = cell
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func writeJSONTo(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// activityTable flattens activity rows using the stable field names.
+// push_days lists the days pushed in the current grid week.
+func activityTable(activity []studentActivity) table {
+	t := table{header: []string{"username", "last_push", "status", "hours_since", "push_days"}}
+	for _, a := range activity {
+		lastPush, hours := "", ""
+		if !a.LastPush.IsZero() {
+			lastPush = a.LastPush.UTC().Format(time.RFC3339)
+			hours = strconv.FormatFloat(a.HoursSince, 'f', 1, 64)
+		}
+		t.rows = append(t.rows, []string{a.Username, lastPush, a.Status, hours, strings.Join(pushedDays(a), " ")})
+	}
+	return t
+}
+
+// pushedDays returns the days a student pushed in the grid week, in order.
+func pushedDays(a studentActivity) []string {
+	var days []string
+	for _, d := range gridDays() {
+		if key := d.Format("2006-01-02"); a.PushDays[key] {
+			days = append(days, key)
+		}
+	}
+	return days
+}
+
+// weekTable is the Week History grid with one column per day.
+func weekTable(activity []studentActivity, days []time.Time) table {
+	t := table{header: []string{"username"}}
+	for _, d := range days {
+		t.header = append(t.header, d.Format("2006-01-02"))
+	}
+	for _, a := range activity {
+		row := []string{a.Username}
+		for _, d := range days {
+			row = append(row, strconv.FormatBool(a.PushDays[d.Format("2006-01-02")]))
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t
+}
+
+// weekRow is the JSON form of one Week History row. Every grid day is
+// present, so false means "no push" rather than "unknown".
+type weekRow struct {
+	Username string          `json:"username"`
+	PushDays map[string]bool `json:"push_days"`
+}
+
+func weekRows(activity []studentActivity, days []time.Time) []weekRow {
+	rows := []weekRow{}
+	for _, a := range activity {
+		row := weekRow{Username: a.Username, PushDays: make(map[string]bool)}
+		for _, d := range days {
+			key := d.Format("2006-01-02")
+			row.PushDays[key] = a.PushDays[key]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func rosterTable(roster []student) table {
+	t := table{header: []string{"username", "verified"}}
+	for _, st := range roster {
+		t.rows = append(t.rows, []string{st.Username, strconv.FormatBool(st.Verified)})
+	}
+	return t
+}
+
+// activityText is the Check Activity report as shown in the TUI.
+func activityText(className string, activity []studentActivity) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Activity Report for %s:\n", className))
+	sb.WriteString("----------------------------------------\n")
+
+	for _, a := range activity {
+		switch a.Status {
+		case statusError:
+			sb.WriteString(fmt.Sprintf("%s %s: Error checking activity - %s\n",
+				errorStyle.Render("❌"),
+				errorStyle.Render(a.Username),
+				a.Error,
+			))
+		case statusActive:
+			sb.WriteString(fmt.Sprintf("%s %s: Last push %s ago\n",
+				successStyle.Render(iconSuccess),
+				successStyle.Render(a.Username),
+				formatDuration(time.Since(a.LastPush)),
+			))
+		case statusRecent:
+			sb.WriteString(fmt.Sprintf("%s %s: Last push %s ago\n",
+				warningStyle.Render(iconWarning),
+				warningStyle.Render(a.Username),
+				formatDuration(time.Since(a.LastPush)),
+			))
+		default:
+			sb.WriteString(fmt.Sprintf("%s %s: Last push %s ago\n",
+				errorStyle.Render(iconError),
+				errorStyle.Render(a.Username),
+				formatDuration(time.Since(a.LastPush)),
+			))
+		}
+	}
+	return sb.String()
+}
+
+// rosterText is the List Students output as shown in the TUI.
+func rosterText(className string, roster []student) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Students in %s:\n", className))
+	for _, st := range roster {
+		if st.Verified {
+			sb.WriteString(fmt.Sprintf("- %s\n", st.Username))
+		} else {
+			sb.WriteString(fmt.Sprintf("- %s %s\n", st.Username, warningStyle.Render("(unverified)")))
+		}
+	}
+	return sb.String()
+}
+
+// weekText renders the Week History grid for a terminal. Cells are left
+// unstyled because escape codes would throw off the column alignment.
+func weekText(activity []studentActivity, days []time.Time) string {
+	t := table{header: []string{"Username"}}
+	for _, d := range days {
+		t.header = append(t.header, d.Format("Mon 01/02"))
+	}
+	for _, a := range activity {
+		row := []string{a.Username}
+		for _, d := range days {
+			if a.PushDays[d.Format("2006-01-02")] {
+				row = append(row, iconSuccess)
+			} else {
+				row = append(row, iconError)
+			}
+		}
+		t.rows = append(t.rows, row)
+	}
+
+	var sb strings.Builder
+	t.write(&sb, formatText)
+	return sb.String()
+}
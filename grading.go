@@ -0,0 +1,645 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// criterion is one line of an assignment's rubric.
+type criterion struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Points float64 `json:"points"`
+}
+
+// studentGrade is a student's rubric scores for one assignment. Scores and
+// Comments are keyed by criterion ID; a criterion without a score hasn't
+// been graded yet.
+type studentGrade struct {
+	Username string
+	Scores   map[int]float64
+	Comments map[int]string
+	Comment  string // overall feedback
+	GradedAt time.Time
+}
+
+// total adds up the graded criteria.
+func (g studentGrade) total() float64 {
+	sum := 0.0
+	for _, score := range g.Scores {
+		sum += score
+	}
+	return sum
+}
+
+// formatPoints shows points to at most two decimal places, so sums such as
+// 0.1 + 0.2 don't print as 0.30000000000000004.
+func formatPoints(p float64) string {
+	return strconv.FormatFloat(math.Round(p*100)/100, 'f', -1, 64)
+}
+
+// rubric returns an assignment's criteria in the order they were added.
+func rubric(a assignment) ([]criterion, error) {
+	rows, err := db.Query("SELECT id, name, points FROM rubric_criteria WHERE assignment_id = ? ORDER BY position, id", a.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var criteria []criterion
+	for rows.Next() {
+		var c criterion
+		if err := rows.Scan(&c.ID, &c.Name, &c.Points); err != nil {
+			return nil, err
+		}
+		criteria = append(criteria, c)
+	}
+	return criteria, rows.Err()
+}
+
+func rubricTotal(criteria []criterion) float64 {
+	sum := 0.0
+	for _, c := range criteria {
+		sum += c.Points
+	}
+	return sum
+}
+
+// addCriterion adds a criterion to an assignment's rubric, or changes the
+// points of an existing one.
+func addCriterion(className, assignmentName, name string, points float64) (string, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return "", err
+	}
+	if points <= 0 {
+		return "", fmt.Errorf("points must be positive")
+	}
+	_, err = db.Exec(`
+		INSERT INTO rubric_criteria (assignment_id, name, points, position)
+		VALUES (?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM rubric_criteria WHERE assignment_id = ?))
+		ON CONFLICT(assignment_id, name) DO UPDATE SET points = excluded.points`,
+		a.ID, name, points, a.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to add criterion: %v", err)
+	}
+	return fmt.Sprintf("Added criterion: %s (%s points) to assignment: %s\n", name, formatPoints(points), a.Name), nil
+}
+
+// removeCriterion removes a criterion and the scores given for it.
+func removeCriterion(className, assignmentName, name string) (string, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return "", err
+	}
+	c, err := findCriterion(a, name)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM grades WHERE criterion_id = ?", c.ID); err != nil {
+		return "", fmt.Errorf("failed to remove scores: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM rubric_criteria WHERE id = ?", c.ID); err != nil {
+		return "", fmt.Errorf("failed to remove criterion: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Removed criterion: %s from assignment: %s\n", c.Name, a.Name), nil
+}
+
+func findCriterion(a assignment, name string) (criterion, error) {
+	var c criterion
+	err := db.QueryRow("SELECT id, name, points FROM rubric_criteria WHERE assignment_id = ? AND name = ?", a.ID, name).
+		Scan(&c.ID, &c.Name, &c.Points)
+	if err != nil {
+		return c, fmt.Errorf("criterion %s not found in assignment: %s", name, a.Name)
+	}
+	return c, nil
+}
+
+func showRubric(className, assignmentName string) (string, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return "", err
+	}
+	criteria, err := rubric(a)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Rubric for %s:\n", a.Name))
+	for _, c := range criteria {
+		sb.WriteString(fmt.Sprintf("- %s: %s points\n", c.Name, formatPoints(c.Points)))
+	}
+	sb.WriteString(fmt.Sprintf("Total: %s points\n", formatPoints(rubricTotal(criteria))))
+	return sb.String(), nil
+}
+
+// assignmentGrades loads every stored grade for an assignment, keyed by
+// username.
+func assignmentGrades(a assignment) (map[string]*studentGrade, error) {
+	grades := make(map[string]*studentGrade)
+	get := func(username string) *studentGrade {
+		g, ok := grades[username]
+		if !ok {
+			g = &studentGrade{Username: username, Scores: make(map[int]float64), Comments: make(map[int]string)}
+			grades[username] = g
+		}
+		return g
+	}
+
+	rows, err := db.Query("SELECT username, criterion_id, score, comment, graded_at FROM grades WHERE assignment_id = ?", a.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var username, comment string
+		var id int
+		var score sql.NullFloat64
+		var gradedAt time.Time
+		if err := rows.Scan(&username, &id, &score, &comment, &gradedAt); err != nil {
+			return nil, err
+		}
+		g := get(username)
+		if score.Valid {
+			g.Scores[id] = score.Float64
+		}
+		if comment != "" {
+			g.Comments[id] = comment
+		}
+		if gradedAt.After(g.GradedAt) {
+			g.GradedAt = gradedAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query("SELECT username, comment, graded_at FROM grade_comments WHERE assignment_id = ?", a.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var username, comment string
+		var gradedAt time.Time
+		if err := rows.Scan(&username, &comment, &gradedAt); err != nil {
+			return nil, err
+		}
+		g := get(username)
+		g.Comment = comment
+		if gradedAt.After(g.GradedAt) {
+			g.GradedAt = gradedAt
+		}
+	}
+	return grades, rows.Err()
+}
+
+// saveGrade stores a student's scores and comments for an assignment,
+// replacing what was there. Criteria missing from g.Scores are left
+// ungraded.
+func saveGrade(a assignment, criteria []criterion, g studentGrade) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for _, c := range criteria {
+		var score any
+		if s, ok := g.Scores[c.ID]; ok {
+			score = s
+		}
+		comment := g.Comments[c.ID]
+		if score == nil && comment == "" {
+			if _, err := tx.Exec("DELETE FROM grades WHERE assignment_id = ? AND username = ? AND criterion_id = ?", a.ID, g.Username, c.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO grades (assignment_id, username, criterion_id, score, comment, graded_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(assignment_id, username, criterion_id) DO UPDATE SET
+				score = excluded.score, comment = excluded.comment, graded_at = excluded.graded_at
+			WHERE score IS NOT excluded.score OR comment IS NOT excluded.comment`,
+			a.ID, g.Username, c.ID, score, comment, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	if g.Comment == "" {
+		_, err = tx.Exec("DELETE FROM grade_comments WHERE assignment_id = ? AND username = ?", a.ID, g.Username)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO grade_comments (assignment_id, username, comment, graded_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(assignment_id, username) DO UPDATE SET
+				comment = excluded.comment, graded_at = excluded.graded_at
+			WHERE comment IS NOT excluded.comment`,
+			a.ID, g.Username, g.Comment, now)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// parseCriterionScore checks a score typed for a criterion.
+func parseCriterionScore(c criterion, text string) (float64, error) {
+	score, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || score < 0 || score > c.Points {
+		return 0, fmt.Errorf("score for %s must be a number from 0 to %s", c.Name, formatPoints(c.Points))
+	}
+	return score, nil
+}
+
+// gradeStudent sets one criterion's score (and optionally its comment) from
+// the command line. Without a comment, the existing one is kept.
+func gradeStudent(className, assignmentName, username, criterionName, scoreText, comment string) (string, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return "", err
+	}
+	if err := requireStudent(className, username); err != nil {
+		return "", err
+	}
+	c, err := findCriterion(a, criterionName)
+	if err != nil {
+		return "", err
+	}
+	score, err := parseCriterionScore(c, scoreText)
+	if err != nil {
+		return "", err
+	}
+	_, err = db.Exec(`
+		INSERT INTO grades (assignment_id, username, criterion_id, score, comment, graded_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(assignment_id, username, criterion_id) DO UPDATE SET
+			score = excluded.score, comment = COALESCE(NULLIF(excluded.comment, ''), comment),
+			graded_at = excluded.graded_at`,
+		a.ID, username, c.ID, score, comment, time.Now().UTC())
+	if err != nil {
+		return "", fmt.Errorf("failed to save grade: %v", err)
+	}
+	return fmt.Sprintf("%s: %s %s/%s\n", username, c.Name, formatPoints(score), formatPoints(c.Points)), nil
+}
+
+// setGradeComment sets a student's overall feedback on an assignment; an
+// empty comment clears it.
+func setGradeComment(className, assignmentName, username, comment string) (string, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return "", err
+	}
+	if err := requireStudent(className, username); err != nil {
+		return "", err
+	}
+	if comment == "" {
+		_, err = db.Exec("DELETE FROM grade_comments WHERE assignment_id = ? AND username = ?", a.ID, username)
+	} else {
+		_, err = db.Exec(`
+			INSERT INTO grade_comments (assignment_id, username, comment, graded_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(assignment_id, username) DO UPDATE SET comment = excluded.comment, graded_at = excluded.graded_at`,
+			a.ID, username, comment, time.Now().UTC())
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save comment: %v", err)
+	}
+	if comment == "" {
+		return fmt.Sprintf("Cleared comment for %s on %s\n", username, a.Name), nil
+	}
+	return fmt.Sprintf("Saved comment for %s on %s\n", username, a.Name), nil
+}
+
+// requireStudent fails unless username is an active student of the class.
+func requireStudent(className, username string) error {
+	usernames, err := classStudents(className)
+	if err != nil {
+		return err
+	}
+	for _, u := range usernames {
+		if u == username {
+			return nil
+		}
+	}
+	return fmt.Errorf("student %s not found in class %s", username, className)
+}
+
+// assignmentGradebook has a column per criterion, the total and the
+// overall comment for every student in the class.
+func assignmentGradebook(className, assignmentName string) (table, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return table{}, err
+	}
+	criteria, err := rubric(a)
+	if err != nil {
+		return table{}, err
+	}
+	grades, err := assignmentGrades(a)
+	if err != nil {
+		return table{}, err
+	}
+	usernames, err := classStudents(className)
+	if err != nil {
+		return table{}, err
+	}
+
+	t := table{header: []string{"username"}}
+	for _, c := range criteria {
+		t.header = append(t.header, fmt.Sprintf("%s (%s)", c.Name, formatPoints(c.Points)))
+	}
+	t.header = append(t.header, "total", "max", "comment")
+	for _, username := range usernames {
+		row := []string{username}
+		g, ok := grades[username]
+		for _, c := range criteria {
+			score := ""
+			if ok {
+				if s, graded := g.Scores[c.ID]; graded {
+					score = formatPoints(s)
+				}
+			}
+			row = append(row, score)
+		}
+		total, comment := "", ""
+		if ok {
+			total, comment = formatPoints(g.total()), g.Comment
+		}
+		t.rows = append(t.rows, append(row, total, formatPoints(rubricTotal(criteria)), comment))
+	}
+	return t, nil
+}
+
+// classGradebook has a column per graded assignment with each student's
+// total, plus the overall total.
+func classGradebook(className string) (table, error) {
+	assignments, err := classAssignments(className)
+	if err != nil {
+		return table{}, err
+	}
+	usernames, err := classStudents(className)
+	if err != nil {
+		return table{}, err
+	}
+
+	t := table{header: []string{"username"}}
+	totals := make(map[string]float64)
+	columns := make([]map[string]float64, 0, len(assignments))
+	maxTotal := 0.0
+	for _, a := range assignments {
+		criteria, err := rubric(a)
+		if err != nil {
+			return table{}, err
+		}
+		if len(criteria) == 0 {
+			continue
+		}
+		grades, err := assignmentGrades(a)
+		if err != nil {
+			return table{}, err
+		}
+		points := rubricTotal(criteria)
+		maxTotal += points
+		t.header = append(t.header, fmt.Sprintf("%s (%s)", a.Name, formatPoints(points)))
+		column := make(map[string]float64)
+		for username, g := range grades {
+			if len(g.Scores) > 0 {
+				column[username] = g.total()
+				totals[username] += g.total()
+			}
+		}
+		columns = append(columns, column)
+	}
+	t.header = append(t.header, "total", "max")
+
+	for _, username := range usernames {
+		row := []string{username}
+		for _, column := range columns {
+			score := ""
+			if s, ok := column[username]; ok {
+				score = formatPoints(s)
+			}
+			row = append(row, score)
+		}
+		// Like the per-assignment gradebook, nothing graded is no total.
+		total := ""
+		if s, ok := totals[username]; ok {
+			total = formatPoints(s)
+		}
+		t.rows = append(t.rows, append(row, total, formatPoints(maxTotal)))
+	}
+	return t, nil
+}
+
+// gradingInfo is the repository summary shown next to the grading form.
+func gradingInfo(username string, repo repoPattern, a assignment, base string, check *checkResult) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[yellow]%s[-]\n", username))
+	sb.WriteString(fmt.Sprintf("Repo:  %s\n", repo.url(username)))
+	sb.WriteString(fmt.Sprintf("Pages: %s\n\n", repo.pagesURL(username)))
+	if !isCloned(username) {
+		sb.WriteString("[red]Not cloned[-]\n")
+		return sb.String()
+	}
+
+	if commits, err := vcs.Log(username, "", 1); err == nil && len(commits) == 1 {
+		c := commits[0]
+		sb.WriteString(fmt.Sprintf("HEAD:  %.7s %s\n       %s\n", c.SHA, c.Committed.Local().Format(deadlineLayout), tview.Escape(c.Subject)))
+	}
+
+	s := studentSubmission(username, a, base)
+	switch s.Status {
+	case submissionError:
+		sb.WriteString(fmt.Sprintf("Status: [red]%s[-]\n", tview.Escape(s.Error)))
+	case submissionLate:
+		sb.WriteString(fmt.Sprintf("Status: [orange]late by %s[-], %d commits\n", formatDuration(s.LateBy), s.Commits))
+	default:
+		sb.WriteString(fmt.Sprintf("Status: %s, %d commits\n", strings.ReplaceAll(s.Status, "_", " "), s.Commits))
+	}
+
+	if !a.DueAt.IsZero() {
+		tag := deadlineTag(a)
+		if sha, err := vcs.Resolve(username, tag); err == nil {
+			sb.WriteString(fmt.Sprintf("Snapshot: %.7s (%s)\n", sha, tag))
+		}
+	}
+	if check != nil {
+		sb.WriteString(fmt.Sprintf("Check: %s\n", tview.Escape(checkLine(*check))))
+	}
+	return sb.String()
+}
+
+// showGradingScreen walks through a class one student at a time, with the
+// student's repository details on the left and the rubric on the right.
+// Scores are saved when moving to another student or leaving the screen.
+func showGradingScreen(className, assignmentName string) error {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return err
+	}
+	criteria, err := rubric(a)
+	if err != nil {
+		return err
+	}
+	if len(criteria) == 0 {
+		return fmt.Errorf("assignment %s has no rubric; add criteria with scv add-criterion", a.Name)
+	}
+	usernames, err := classStudents(className)
+	if err != nil {
+		return err
+	}
+	if len(usernames) == 0 {
+		return fmt.Errorf("no students in class: %s", className)
+	}
+	repo, err := classRepoPattern(className)
+	if err != nil {
+		return err
+	}
+	grades, err := assignmentGrades(a)
+	if err != nil {
+		return err
+	}
+	var base string
+	if a.StarterRepo != "" {
+		// Without the starter, status counts every commit; still usable.
+		base, _ = syncStarter(a)
+	}
+	checks := make(map[string]*checkResult)
+	if _, results, err := storedCheckResults(className, assignmentName); err == nil {
+		for i := range results {
+			checks[results[i].Username] = &results[i]
+		}
+	}
+
+	app := tview.NewApplication()
+	header := tview.NewTextView().SetDynamicColors(true)
+	status := tview.NewTextView().SetDynamicColors(true)
+	info := tview.NewTextView().SetDynamicColors(true)
+	form := tview.NewForm()
+	info.SetBorder(true).SetTitle("Repository")
+	form.SetBorder(true).SetTitle("Rubric")
+
+	index := 0
+	var scoreFields, commentFields []*tview.InputField
+	var overall *tview.TextArea
+
+	// save reads the form back into the student's grade. It refuses to
+	// leave a student while a score is invalid.
+	save := func() bool {
+		g := studentGrade{Username: usernames[index], Scores: make(map[int]float64), Comments: make(map[int]string)}
+		for i, c := range criteria {
+			if text := strings.TrimSpace(scoreFields[i].GetText()); text != "" {
+				score, err := parseCriterionScore(c, text)
+				if err != nil {
+					status.SetText("[red]" + tview.Escape(err.Error()))
+					app.SetFocus(scoreFields[i])
+					return false
+				}
+				g.Scores[c.ID] = score
+			}
+			if comment := strings.TrimSpace(commentFields[i].GetText()); comment != "" {
+				g.Comments[c.ID] = comment
+			}
+		}
+		g.Comment = strings.TrimSpace(overall.GetText())
+		if err := saveGrade(a, criteria, g); err != nil {
+			status.SetText("[red]" + tview.Escape(err.Error()))
+			return false
+		}
+		grades[g.Username] = &g
+		status.SetText(fmt.Sprintf("[green]Saved %s: %s/%s", g.Username, formatPoints(g.total()), formatPoints(rubricTotal(criteria))))
+		return true
+	}
+
+	load := func(i int) {
+		index = i
+		username := usernames[i]
+		g := grades[username]
+		header.SetText(fmt.Sprintf("[yellow]%s[white]  %s  (%d/%d)   [gray]Tab: next field · Ctrl-N/Ctrl-P: next/previous student · Ctrl-S: save · Esc: save and back",
+			a.Name, username, i+1, len(usernames)))
+		info.SetText(gradingInfo(username, repo, a, base, checks[username])).ScrollToBeginning()
+
+		form.Clear(true)
+		scoreFields, commentFields = nil, nil
+		for _, c := range criteria {
+			score, comment := "", ""
+			if g != nil {
+				if s, ok := g.Scores[c.ID]; ok {
+					score = formatPoints(s)
+				}
+				comment = g.Comments[c.ID]
+			}
+			scoreField := tview.NewInputField().SetLabel(fmt.Sprintf("%s (/%s)", c.Name, formatPoints(c.Points))).
+				SetText(score).SetFieldWidth(8)
+			commentField := tview.NewInputField().SetLabel("  comment").SetText(comment)
+			form.AddFormItem(scoreField).AddFormItem(commentField)
+			scoreFields = append(scoreFields, scoreField)
+			commentFields = append(commentFields, commentField)
+		}
+		comment := ""
+		if g != nil {
+			comment = g.Comment
+		}
+		overall = tview.NewTextArea().SetLabel("Feedback").SetText(comment, false)
+		overall.SetSize(5, 0)
+		form.AddFormItem(overall)
+		form.SetFocus(0)
+		app.SetFocus(form)
+	}
+
+	body := tview.NewFlex().
+		AddItem(info, 0, 2, false).
+		AddItem(form, 0, 3, true)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(header, 1, 0, false).
+		AddItem(body, 0, 1, true).
+		AddItem(status, 1, 0, false)
+
+	layout.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			if save() {
+				app.Stop()
+			}
+			return nil
+		case tcell.KeyCtrlS:
+			save()
+			return nil
+		case tcell.KeyCtrlN:
+			if save() {
+				load((index + 1) % len(usernames))
+			}
+			return nil
+		case tcell.KeyCtrlP:
+			if save() {
+				load((index + len(usernames) - 1) % len(usernames))
+			}
+			return nil
+		}
+		return event
+	})
+
+	load(0)
+	return app.SetRoot(layout, true).Run()
+}
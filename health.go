@@ -0,0 +1,313 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// repoHealthMaxAge is how long a stored health check is reused before
+// GitHub is asked again.
+const repoHealthMaxAge = time.Hour
+
+// Pages states in repoHealth.Pages. Anything else is GitHub's own build
+// status ("building", "queued").
+const (
+	pagesDisabled = "disabled"
+	pagesBuilt    = "built"
+	pagesErrored  = "errored"
+	pagesUnknown  = "unknown"
+)
+
+// States of the local clone compared with GitHub.
+const (
+	cloneMissing  = "not_cloned"
+	cloneInSync   = "in_sync"
+	cloneBehind   = "behind"    // GitHub has commits the clone hasn't fetched
+	cloneAhead    = "ahead"     // the clone has commits GitHub doesn't (teacher edits, force-push)
+	cloneOtherURL = "other_url" // origin isn't the student's repository
+	cloneShallow  = "shallow"   // differs from GitHub, and a shallow clone can't tell how
+)
+
+// repoHealth is what GitHub and the local clone say about a student's
+// repository. Error is set when the check itself failed.
+type repoHealth struct {
+	Username      string    `json:"username"`
+	Repo          string    `json:"repo"`
+	CheckedAt     time.Time `json:"checked_at"`
+	Exists        bool      `json:"exists"`
+	Private       bool      `json:"private"`
+	DefaultBranch string    `json:"default_branch"`
+	Pages         string    `json:"pages"`
+	PagesURL      string    `json:"pages_url"`
+	PagesError    string    `json:"pages_error,omitempty"`
+	RemoteSHA     string    `json:"remote_sha"`
+	LocalSHA      string    `json:"local_sha"`
+	Clone         string    `json:"clone"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// problems lists what is wrong with the repository, for the report.
+func (h repoHealth) problems() []string {
+	if h.Error != "" {
+		return []string{"check failed: " + h.Error}
+	}
+	if !h.Exists {
+		return []string{fmt.Sprintf("repository %s not found or not accessible", h.Repo)}
+	}
+	var problems []string
+	if h.DefaultBranch == "" {
+		problems = append(problems, "no default branch (empty repository)")
+	}
+	switch h.Pages {
+	case pagesBuilt, pagesUnknown:
+	case pagesDisabled:
+		problems = append(problems, "GitHub Pages is not enabled")
+	case pagesErrored:
+		msg := "the last Pages build failed"
+		if h.PagesError != "" {
+			msg += ": " + h.PagesError
+		}
+		problems = append(problems, msg)
+	default:
+		problems = append(problems, "Pages build is "+h.Pages)
+	}
+	switch h.Clone {
+	case cloneMissing:
+		problems = append(problems, "not cloned")
+	case cloneBehind:
+		problems = append(problems, "local clone is behind GitHub")
+	case cloneAhead:
+		problems = append(problems, "local clone has commits GitHub doesn't")
+	case cloneOtherURL:
+		problems = append(problems, "local clone points at a different repository")
+	case cloneShallow:
+		problems = append(problems, "local clone differs from GitHub (history unavailable in a shallow clone)")
+	}
+	return problems
+}
+
+// sameRepoURL compares clone URLs, ignoring scheme, credentials, case and a
+// trailing .git.
+func sameRepoURL(a, b string) bool {
+	norm := func(s string) string {
+		s = strings.TrimSpace(s)
+		if u, err := url.Parse(s); err == nil && u.Host != "" {
+			s = u.Host + u.Path
+		} else if host, path, ok := strings.Cut(strings.TrimPrefix(s, "git@"), ":"); ok {
+			s = host + "/" + path // scp-style git@github.com:user/repo
+		}
+		return strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(s, "/"), ".git"))
+	}
+	return norm(a) == norm(b)
+}
+
+// localCloneState compares the clone with the commit GitHub has on the
+// default branch.
+func localCloneState(username string, repo repoPattern, remoteSHA string) (state, localSHA string) {
+	if !isCloned(username) {
+		return cloneMissing, ""
+	}
+	localSHA, _ = vcs.Head(username)
+	origin, _ := vcs.RemoteURL(username)
+	switch {
+	case !sameRepoURL(origin, repo.url(username)):
+		return cloneOtherURL, localSHA
+	case remoteSHA == "" || remoteSHA == localSHA:
+		return cloneInSync, localSHA
+	}
+	if _, err := vcs.Resolve(username, remoteSHA); err != nil {
+		return cloneBehind, localSHA
+	}
+	if ok, _ := vcs.IsAncestor(username, localSHA, remoteSHA); ok {
+		return cloneBehind, localSHA // fetched but not merged
+	}
+	if isShallow(username) {
+		return cloneShallow, localSHA
+	}
+	return cloneAhead, localSHA
+}
+
+// checkRepoHealth asks GitHub about a student's repository and its Pages
+// site and compares it with the local clone.
+func checkRepoHealth(username string, repo repoPattern) repoHealth {
+	h := repoHealth{Username: username, Repo: repo.name(username), CheckedAt: time.Now(), Pages: pagesUnknown}
+	base := fmt.Sprintf("%s/repos/%s/%s", githubAPI, username, h.Repo)
+
+	var info struct {
+		Private       bool   `json:"private"`
+		DefaultBranch string `json:"default_branch"`
+	}
+	err := githubJSON("GET", base, nil, &info)
+	if errors.Is(err, errGithubNotFound) {
+		h.Clone, h.LocalSHA = localCloneState(username, repo, "")
+		return h
+	}
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	h.Exists, h.Private = true, info.Private
+
+	// An empty repository still reports a default branch name, but the
+	// branch itself doesn't exist.
+	var branch struct {
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+	if info.DefaultBranch != "" {
+		err := githubJSON("GET", base+"/branches/"+url.PathEscape(info.DefaultBranch), nil, &branch)
+		switch {
+		case err == nil:
+			h.DefaultBranch, h.RemoteSHA = info.DefaultBranch, branch.Commit.SHA
+		case !errors.Is(err, errGithubNotFound):
+			h.Error = err.Error()
+			return h
+		}
+	}
+
+	var pages struct {
+		Status  string `json:"status"`
+		HTMLURL string `json:"html_url"`
+	}
+	err = githubJSON("GET", base+"/pages", nil, &pages)
+	switch {
+	case errors.Is(err, errGithubNotFound):
+		h.Pages = pagesDisabled
+	case err != nil:
+		// Reading Pages settings can need more access than the teacher has.
+		h.PagesError = err.Error()
+	default:
+		h.PagesURL = pages.HTMLURL
+		if pages.Status != "" {
+			h.Pages = pages.Status
+		}
+		var build struct {
+			Status string `json:"status"`
+			Error  struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if githubJSON("GET", base+"/pages/builds/latest", nil, &build) == nil && build.Status != "" {
+			h.Pages, h.PagesError = build.Status, build.Error.Message
+		}
+	}
+
+	h.Clone, h.LocalSHA = localCloneState(username, repo, h.RemoteSHA)
+	return h
+}
+
+func saveRepoHealth(h repoHealth) error {
+	var checkErr any
+	if h.Error != "" {
+		checkErr = h.Error
+	}
+	_, err := db.Exec(`
+		INSERT INTO repo_health (username, repo, checked_at, repo_exists, private, default_branch, pages, pages_url, pages_error, remote_sha, local_sha, clone, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(username) DO UPDATE SET
+			repo = excluded.repo, checked_at = excluded.checked_at, repo_exists = excluded.repo_exists, private = excluded.private,
+			default_branch = excluded.default_branch, pages = excluded.pages, pages_url = excluded.pages_url,
+			pages_error = excluded.pages_error, remote_sha = excluded.remote_sha, local_sha = excluded.local_sha,
+			clone = excluded.clone, error = excluded.error`,
+		h.Username, h.Repo, h.CheckedAt.UTC(), h.Exists, h.Private, h.DefaultBranch, h.Pages, h.PagesURL, h.PagesError,
+		h.RemoteSHA, h.LocalSHA, h.Clone, checkErr)
+	return err
+}
+
+func storedRepoHealth(username string) (repoHealth, error) {
+	h := repoHealth{Username: username}
+	var checkErr sql.NullString
+	err := db.QueryRow(`
+		SELECT repo, checked_at, repo_exists, private, default_branch, pages, pages_url, pages_error, remote_sha, local_sha, clone, error
+		FROM repo_health WHERE username = ?`,
+		username).Scan(&h.Repo, &h.CheckedAt, &h.Exists, &h.Private, &h.DefaultBranch, &h.Pages, &h.PagesURL, &h.PagesError,
+		&h.RemoteSHA, &h.LocalSHA, &h.Clone, &checkErr)
+	h.Error = checkErr.String
+	return h, err
+}
+
+// classRepoHealth returns the health of every student's repository. Stored
+// results younger than repoHealthMaxAge are reused unless refresh is set;
+// failed checks and checks of a repository the class no longer expects are
+// always retried.
+func classRepoHealth(className string, refresh bool) ([]repoHealth, error) {
+	usernames, err := classStudents(className)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := classRepoPattern(className)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []repoHealth
+	for _, username := range usernames {
+		h, err := storedRepoHealth(username)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if refresh || err != nil || h.Error != "" || h.Repo != repo.name(username) || time.Since(h.CheckedAt) > repoHealthMaxAge {
+			h = checkRepoHealth(username, repo)
+			if err := saveRepoHealth(h); err != nil {
+				return nil, fmt.Errorf("failed to save repo health: %v", err)
+			}
+		} else {
+			// The clone can change without GitHub changing.
+			h.Clone, h.LocalSHA = localCloneState(username, repo, h.RemoteSHA)
+		}
+		result = append(result, h)
+	}
+	return result, nil
+}
+
+func repoHealthTable(result []repoHealth) table {
+	t := table{header: []string{"username", "exists", "private", "default_branch", "pages", "clone", "problems", "checked_at"}}
+	for _, h := range result {
+		t.rows = append(t.rows, []string{
+			h.Username, strconv.FormatBool(h.Exists), strconv.FormatBool(h.Private), h.DefaultBranch, h.Pages, h.Clone,
+			strings.Join(h.problems(), "; "), h.CheckedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return t
+}
+
+// repoHealthText groups students into healthy repositories and those that
+// need attention, as shown in the TUI.
+func repoHealthText(className string, result []repoHealth) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Repo Health for %s:\n", className))
+	sb.WriteString("----------------------------------------\n")
+	healthy := 0
+	for _, h := range result {
+		problems := h.problems()
+		visibility := "public"
+		if h.Private {
+			visibility = "private"
+		}
+		switch {
+		case h.Error != "":
+			sb.WriteString(fmt.Sprintf("%s %s: %s\n", errorStyle.Render(iconError), errorStyle.Render(h.Username), problems[0]))
+		case !h.Exists:
+			sb.WriteString(fmt.Sprintf("%s %s: %s\n", errorStyle.Render(iconError), errorStyle.Render(h.Username), problems[0]))
+		case len(problems) > 0:
+			sb.WriteString(fmt.Sprintf("%s %s (%s): %s\n", warningStyle.Render(iconWarning), warningStyle.Render(h.Username),
+				visibility, strings.Join(problems, "; ")))
+		default:
+			healthy++
+			pages := "Pages built"
+			if h.Pages == pagesUnknown {
+				pages = "Pages status unknown"
+			}
+			sb.WriteString(fmt.Sprintf("%s %s (%s): %s, clone in sync\n", successStyle.Render(iconSuccess),
+				successStyle.Render(h.Username), visibility, pages))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\n%d of %d repositories healthy\n", healthy, len(result)))
+	return sb.String()
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Kinds of problem the site linter reports, in report column order.
+const (
+	lintMissingIndex = "missing_index"
+	lintBrokenLink   = "broken_link"
+	lintMissingImage = "missing_image"
+	lintMissingAlt   = "missing_alt"
+	lintUnclosedTag  = "unclosed_tag"
+	lintCSS          = "css_error"
+)
+
+var lintKinds = []string{lintMissingIndex, lintBrokenLink, lintMissingImage, lintMissingAlt, lintUnclosedTag, lintCSS}
+
+// lintIssue is one problem found in a student's site. Line is 0 when the
+// problem isn't tied to a line.
+type lintIssue struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// siteLint is the linter's result for one student.
+type siteLint struct {
+	Username string      `json:"username"`
+	Files    int         `json:"files"` // HTML and CSS files checked
+	Issues   []lintIssue `json:"issues"`
+	Error    string      `json:"error,omitempty"`
+}
+
+func (s siteLint) count(kind string) int {
+	n := 0
+	for _, issue := range s.Issues {
+		if issue.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// voidElements never have a closing tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// optionalEndTags may legally be left open.
+var optionalEndTags = map[string]bool{
+	"html": true, "head": true, "body": true, "p": true, "li": true, "dt": true, "dd": true,
+	"option": true, "optgroup": true, "tr": true, "td": true, "th": true, "thead": true,
+	"tbody": true, "tfoot": true, "colgroup": true, "rt": true, "rp": true,
+}
+
+// linkAttrs are the attributes that point at other files.
+var linkAttrs = map[string]string{
+	"a": "href", "link": "href", "area": "href",
+	"img": "src", "script": "src", "source": "src", "iframe": "src", "audio": "src", "video": "src", "embed": "src",
+}
+
+// siteLinter checks the files of one cloned site.
+type siteLinter struct {
+	root   string
+	issues []lintIssue
+}
+
+func (l *siteLinter) report(file string, line int, kind, format string, args ...any) {
+	l.issues = append(l.issues, lintIssue{File: file, Line: line, Kind: kind, Message: fmt.Sprintf(format, args...)})
+}
+
+// resolve maps a link found in file to a path in the site, or "" for
+// links the linter doesn't check (other sites, mailto:, anchors...).
+func resolve(file, ref string) string {
+	ref = strings.TrimSpace(ref)
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(ref, "//") {
+		return ""
+	}
+	if strings.HasPrefix(u.Path, "/") {
+		return path.Clean(strings.TrimPrefix(u.Path, "/"))
+	}
+	return path.Join(path.Dir(file), u.Path)
+}
+
+// exists reports whether a site path is served: a file, or a directory
+// with an index.html.
+func (l *siteLinter) exists(rel string) bool {
+	if rel == "." || rel == "" {
+		rel = "index.html"
+	}
+	if !fs.ValidPath(rel) {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(l.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		return l.exists(path.Join(rel, "index.html"))
+	}
+	return true
+}
+
+type openTag struct {
+	name string
+	line int
+}
+
+func (l *siteLinter) lintHTML(file string, data []byte) {
+	z := html.NewTokenizer(bytes.NewReader(data))
+	line := 1
+	var stack []openTag
+	var style strings.Builder
+	styleLine, inStyle := 0, false
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				l.report(file, line, lintUnclosedTag, "could not parse HTML: %v", z.Err())
+			}
+			break
+		}
+		tokenLine := line
+		raw := z.Raw()
+		line += bytes.Count(raw, []byte("\n"))
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			l.lintTag(file, tokenLine, tok)
+			if tok.Data == "style" && tt == html.StartTagToken {
+				inStyle, styleLine = true, tokenLine
+				style.Reset()
+			}
+			if tt == html.StartTagToken && !voidElements[tok.Data] {
+				stack = append(stack, openTag{tok.Data, tokenLine})
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if tag == "style" && inStyle {
+				inStyle = false
+				l.lintCSS(file, styleLine, style.String())
+			}
+			// Close back to the matching tag; anything skipped over that
+			// needs an end tag was left open.
+			match := -1
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == tag {
+					match = i
+					break
+				}
+			}
+			if match < 0 {
+				if !voidElements[tag] {
+					l.report(file, tokenLine, lintUnclosedTag, "</%s> has no matching <%s>", tag, tag)
+				}
+				continue
+			}
+			for _, open := range stack[match+1:] {
+				if !optionalEndTags[open.name] {
+					l.report(file, open.line, lintUnclosedTag, "<%s> is not closed before </%s>", open.name, tag)
+				}
+			}
+			stack = stack[:match]
+
+		case html.TextToken:
+			if inStyle {
+				style.Write(raw)
+			}
+		}
+	}
+
+	for _, open := range stack {
+		if !optionalEndTags[open.name] {
+			l.report(file, open.line, lintUnclosedTag, "<%s> is never closed", open.name)
+		}
+	}
+}
+
+func (l *siteLinter) lintTag(file string, line int, tok html.Token) {
+	attrs := make(map[string]string)
+	for _, a := range tok.Attr {
+		attrs[a.Key] = a.Val
+	}
+
+	if tok.Data == "img" {
+		if _, ok := attrs["alt"]; !ok {
+			l.report(file, line, lintMissingAlt, "<img src=%q> has no alt text", attrs["src"])
+		}
+	}
+
+	attr, ok := linkAttrs[tok.Data]
+	if !ok {
+		return
+	}
+	ref, ok := attrs[attr]
+	if !ok {
+		return
+	}
+	target := resolve(file, ref)
+	if target == "" || l.exists(target) {
+		return
+	}
+	if tok.Data == "img" {
+		l.report(file, line, lintMissingImage, "image %s not found", ref)
+	} else {
+		l.report(file, line, lintBrokenLink, "<%s %s=%q> points to a missing file", tok.Data, attr, ref)
+	}
+}
+
+// lintCSS finds syntax errors a browser would silently skip over:
+// unbalanced braces, unterminated comments and strings, and declarations
+// without a colon. startLine is the line the CSS starts on in file.
+func (l *siteLinter) lintCSS(file string, startLine int, css string) {
+	line := startLine
+	depth := 0
+	var decl strings.Builder
+	declLine := line
+	var opens []int // lines of unclosed {
+
+	checkDecl := func() {
+		d := strings.TrimSpace(decl.String())
+		if depth > 0 && d != "" && !strings.HasPrefix(d, "@") && !strings.Contains(d, ":") {
+			l.report(file, declLine, lintCSS, "declaration %q is missing a colon", d)
+		}
+		decl.Reset()
+	}
+
+	for i := 0; i < len(css); i++ {
+		c := css[i]
+		switch {
+		case c == '\n':
+			line++
+			decl.WriteByte(c)
+
+		case c == '/' && i+1 < len(css) && css[i+1] == '*':
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				l.report(file, line, lintCSS, "comment is never closed")
+				return
+			}
+			line += strings.Count(css[i:i+2+end], "\n")
+			i += end + 3
+
+		case c == '"' || c == '\'':
+			end := strings.IndexAny(css[i+1:], string(c)+"\n")
+			if end < 0 || css[i+1+end] == '\n' {
+				l.report(file, line, lintCSS, "string is never closed")
+				if end < 0 {
+					return
+				}
+				// Stop before the newline so it is still counted.
+				decl.WriteString(css[i : i+1+end])
+				i += end
+				continue
+			}
+			decl.WriteString(css[i : i+1+end+1])
+			i += end + 1
+
+		case c == '{':
+			decl.Reset()
+			depth++
+			opens = append(opens, line)
+			declLine = line
+
+		case c == '}':
+			checkDecl()
+			if depth == 0 {
+				l.report(file, line, lintCSS, "} without a matching {")
+				continue
+			}
+			depth--
+			opens = opens[:len(opens)-1]
+
+		case c == ';':
+			checkDecl()
+
+		default:
+			if decl.Len() == 0 || strings.TrimSpace(decl.String()) == "" {
+				declLine = line
+			}
+			decl.WriteByte(c)
+		}
+	}
+	for _, open := range opens {
+		l.report(file, open, lintCSS, "{ is never closed")
+	}
+}
+
+// lintSite checks a student's cloned GitHub Pages site.
+func lintSite(username string) siteLint {
+	result := siteLint{Username: username}
+	if !isCloned(username) {
+		result.Error = "not cloned"
+		return result
+	}
+
+	l := &siteLinter{root: username}
+	if !l.exists("index.html") {
+		l.report("index.html", 0, lintMissingIndex, "the site has no index.html")
+	}
+
+	err := filepath.WalkDir(username, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if similaritySkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if ext != ".html" && ext != ".htm" && ext != ".css" {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(username, p)
+		rel = filepath.ToSlash(rel)
+		result.Files++
+		if ext == ".css" {
+			l.lintCSS(rel, 1, string(data))
+		} else {
+			l.lintHTML(rel, data)
+		}
+		return nil
+	})
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].File != l.issues[j].File {
+			return l.issues[i].File < l.issues[j].File
+		}
+		return l.issues[i].Line < l.issues[j].Line
+	})
+	result.Issues = l.issues
+	if result.Issues == nil {
+		result.Issues = []lintIssue{}
+	}
+	return result
+}
+
+// lintClass lints every student's site in a class.
+func lintClass(className string) ([]siteLint, error) {
+	usernames, err := classStudents(className)
+	if err != nil {
+		return nil, err
+	}
+	var results []siteLint
+	for _, username := range usernames {
+		results = append(results, lintSite(username))
+	}
+	return results, nil
+}
+
+// lintTable summarises issue counts per student.
+func lintTable(results []siteLint) table {
+	t := table{header: append([]string{"username", "files"}, lintKinds...)}
+	t.header = append(t.header, "error")
+	for _, r := range results {
+		row := []string{r.Username, strconv.Itoa(r.Files)}
+		for _, kind := range lintKinds {
+			row = append(row, strconv.Itoa(r.count(kind)))
+		}
+		t.rows = append(t.rows, append(row, r.Error))
+	}
+	return t
+}
+
+// lintText is the per-student summary as shown in the TUI.
+func lintText(className string, results []siteLint) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Site checks for %s:\n\n", className))
+	lintTable(results).write(&sb, formatText)
+	sb.WriteString("\nRun scv lint <class> <username> for the details of one site.\n")
+	return sb.String()
+}
+
+// lintDetails lists every issue in one student's site.
+func lintDetails(r siteLint) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Site checks for %s: %d files, %d issues\n", r.Username, r.Files, len(r.Issues)))
+	if r.Error != "" {
+		sb.WriteString("Error: " + r.Error + "\n")
+	}
+	for _, issue := range r.Issues {
+		location := issue.File
+		if issue.Line > 0 {
+			location += ":" + strconv.Itoa(issue.Line)
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s (%s)\n", location, issue.Message, issue.Kind))
+	}
+	return sb.String()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLintCSSLines(t *testing.T) {
+	tests := []struct {
+		name string
+		css  string
+		want []string // "line: message"
+	}{
+		{
+			"unclosed string",
+			"a {\n  content: \"oops\n}\nb { color red; }\n",
+			[]string{`2: string is never closed`, `4: declaration "color red" is missing a colon`},
+		},
+		{
+			"closed string",
+			"a {\n  content: \"ok\";\n  color red;\n}\n",
+			[]string{`3: declaration "color red" is missing a colon`},
+		},
+		{
+			"comment across lines",
+			"/* one\ntwo */\na {\n  color red;\n",
+			[]string{`4: declaration "color red" is missing a colon`, `3: { is never closed`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &siteLinter{}
+			l.lintCSS("style.css", 1, tt.css)
+			var got []string
+			for _, issue := range l.issues {
+				got = append(got, fmt.Sprintf("%d: %s", issue.Line, issue.Message))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("issues =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -2,11 +2,8 @@ package main
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
@@ -26,10 +23,17 @@ type GithubEvent struct {
 	Repo      struct {
 		Name string `json:"name"`
 	} `json:"repo"`
+	Payload struct {
+		Size int `json:"size"` // number of commits in a PushEvent
+	} `json:"payload"`
 }
 
 var db *sql.DB
 
+// dbDSN waits on locks instead of failing, since the sync daemon and the
+// TUI may write to the database at the same time.
+const dbDSN = "./students.db?_busy_timeout=5000"
+
 // Styles
 var (
 	titleStyle = lipgloss.NewStyle().
@@ -82,6 +86,11 @@ const (
 	stateMainMenu = iota
 	stateClassInput
 	stateStudentInput
+	stateTargetClassInput
+	stateAssignmentInput
+	stateConfirmUnverified
+	stateConfirmDelete
+	stateRoster
 	stateOutput
 )
 
@@ -95,18 +104,22 @@ func (i item) Description() string { return i.description }
 func (i item) FilterValue() string { return i.title }
 
 type model struct {
-	list         list.Model
-	state        int
-	classInput   textinput.Model
-	studentInput textinput.Model
-	className    string
-	err          error
-	output       string // holds command output to be rendered in stateOutput
+	list            list.Model
+	state           int
+	classInput      textinput.Model
+	studentInput    textinput.Model
+	targetInput     textinput.Model
+	assignmentInput textinput.Model
+	className       string
+	err             error
+	roster          list.Model     // selectable students shown in stateRoster
+	output          string         // holds command output to be rendered in stateOutput
+	checks          []studentCheck // validation results awaiting confirmation
 }
 
 func initDB() error {
 	var err error
-	db, err = sql.Open("sqlite3", "./students.db")
+	db, err = sql.Open("sqlite3", dbDSN)
 	if err != nil {
 		return err
 	}
@@ -114,64 +127,240 @@ func initDB() error {
 	createTable := `
 	CREATE TABLE IF NOT EXISTS classes (
 		id INTEGER PRIMARY KEY,
-		name TEXT UNIQUE
+		name TEXT UNIQUE,
+		archived INTEGER NOT NULL DEFAULT 0,
+		pull_policy TEXT NOT NULL DEFAULT 'skip',
+		clone_depth INTEGER NOT NULL DEFAULT 0,
+		clone_filter TEXT NOT NULL DEFAULT '',
+		clone_sparse TEXT NOT NULL DEFAULT '',
+		clone_single_branch INTEGER NOT NULL DEFAULT 0,
+		repo_pattern TEXT NOT NULL DEFAULT '{user}.github.io'
 	);
 	CREATE TABLE IF NOT EXISTS students (
 		username TEXT,
 		class_id INTEGER,
+		verified INTEGER NOT NULL DEFAULT 1,
+		archived INTEGER NOT NULL DEFAULT 0,
 		FOREIGN KEY(class_id) REFERENCES classes(id),
 		UNIQUE(username, class_id)
-	);`
+	);
+	CREATE TABLE IF NOT EXISTS assignments (
+		id INTEGER PRIMARY KEY,
+		class_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		starter_repo TEXT NOT NULL DEFAULT '',
+		starter_ref TEXT NOT NULL DEFAULT '',
+		assigned_at DATETIME,
+		due_at DATETIME,
+		check_command TEXT NOT NULL DEFAULT '',
+		clone_depth INTEGER NOT NULL DEFAULT 0,
+		clone_filter TEXT NOT NULL DEFAULT '',
+		clone_sparse TEXT NOT NULL DEFAULT '',
+		clone_single_branch INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(class_id) REFERENCES classes(id),
+		UNIQUE(class_id, name)
+	);
+	CREATE TABLE IF NOT EXISTS notes (
+		id INTEGER PRIMARY KEY,
+		username TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		body TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS activity_snapshots (
+		id INTEGER PRIMARY KEY,
+		username TEXT NOT NULL,
+		fetched_at DATETIME NOT NULL,
+		last_push DATETIME,
+		push_count INTEGER NOT NULL DEFAULT 0,
+		commit_count INTEGER NOT NULL DEFAULT 0,
+		local_head TEXT,
+		error TEXT
+	);
+	CREATE TABLE IF NOT EXISTS activity_pushes (
+		username TEXT NOT NULL,
+		repo TEXT NOT NULL,
+		pushed_at DATETIME NOT NULL,
+		commits INTEGER NOT NULL DEFAULT 0,
+		UNIQUE(username, repo, pushed_at)
+	);
+	CREATE INDEX IF NOT EXISTS idx_activity_pushes_user ON activity_pushes(username, pushed_at);
+	CREATE TABLE IF NOT EXISTS sync_runs (
+		id INTEGER PRIMARY KEY,
+		class_name TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME NOT NULL,
+		students INTEGER NOT NULL,
+		pulled INTEGER NOT NULL,
+		fetched INTEGER NOT NULL,
+		errors INTEGER NOT NULL,
+		last_error TEXT
+	);
 
-	_, err = db.Exec(createTable)
-	return err
-}
+	CREATE TABLE IF NOT EXISTS deadline_snapshots (
+		assignment_id INTEGER NOT NULL,
+		username TEXT NOT NULL,
+		taken_at DATETIME NOT NULL,
+		sha TEXT NOT NULL DEFAULT '',
+		committed_at DATETIME,
+		late_commits INTEGER NOT NULL DEFAULT 0,
+		error TEXT,
+		FOREIGN KEY(assignment_id) REFERENCES assignments(id),
+		UNIQUE(assignment_id, username)
+	);
 
-func getLastPushTime(username string) (time.Time, error) {
-	token := os.Getenv("GITHUB_TOKEN")
-	if token == "" {
-		return time.Time{}, fmt.Errorf("GITHUB_TOKEN environment variable not set")
-	}
+	CREATE TABLE IF NOT EXISTS check_results (
+		assignment_id INTEGER NOT NULL,
+		username TEXT NOT NULL,
+		ran_at DATETIME NOT NULL,
+		sha TEXT NOT NULL DEFAULT '',
+		passed INTEGER NOT NULL,
+		exit_code INTEGER NOT NULL,
+		timed_out INTEGER NOT NULL DEFAULT 0,
+		limit_hit TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER NOT NULL,
+		score REAL,
+		max_score REAL,
+		output TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY(assignment_id) REFERENCES assignments(id),
+		UNIQUE(assignment_id, username)
+	);
 
-	url := fmt.Sprintf("https://api.github.com/users/%s/events/public", username)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return time.Time{}, err
-	}
+	CREATE TABLE IF NOT EXISTS rubric_criteria (
+		id INTEGER PRIMARY KEY,
+		assignment_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		points REAL NOT NULL,
+		position INTEGER NOT NULL,
+		FOREIGN KEY(assignment_id) REFERENCES assignments(id),
+		UNIQUE(assignment_id, name)
+	);
+	CREATE TABLE IF NOT EXISTS grades (
+		assignment_id INTEGER NOT NULL,
+		username TEXT NOT NULL,
+		criterion_id INTEGER NOT NULL,
+		score REAL,
+		comment TEXT NOT NULL DEFAULT '',
+		graded_at DATETIME NOT NULL,
+		FOREIGN KEY(assignment_id) REFERENCES assignments(id),
+		FOREIGN KEY(criterion_id) REFERENCES rubric_criteria(id),
+		UNIQUE(assignment_id, username, criterion_id)
+	);
+	CREATE TABLE IF NOT EXISTS grade_comments (
+		assignment_id INTEGER NOT NULL,
+		username TEXT NOT NULL,
+		comment TEXT NOT NULL,
+		graded_at DATETIME NOT NULL,
+		FOREIGN KEY(assignment_id) REFERENCES assignments(id),
+		UNIQUE(assignment_id, username)
+	);
 
-	req.Header.Set("Authorization", "token "+token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	CREATE TABLE IF NOT EXISTS review_notes (
+		id INTEGER PRIMARY KEY,
+		username TEXT NOT NULL,
+		assignment_id INTEGER,
+		sha TEXT NOT NULL,
+		path TEXT NOT NULL,
+		line_start INTEGER NOT NULL,
+		line_end INTEGER NOT NULL,
+		body TEXT NOT NULL,
+		snippet TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY(assignment_id) REFERENCES assignments(id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_review_notes_user ON review_notes(username, path);
+
+	CREATE TABLE IF NOT EXISTS repo_health (
+		username TEXT PRIMARY KEY,
+		repo TEXT NOT NULL DEFAULT '',
+		checked_at DATETIME NOT NULL,
+		repo_exists INTEGER NOT NULL,
+		private INTEGER NOT NULL DEFAULT 0,
+		default_branch TEXT NOT NULL DEFAULT '',
+		pages TEXT NOT NULL DEFAULT '',
+		pages_url TEXT NOT NULL DEFAULT '',
+		pages_error TEXT NOT NULL DEFAULT '',
+		remote_sha TEXT NOT NULL DEFAULT '',
+		local_sha TEXT NOT NULL DEFAULT '',
+		clone TEXT NOT NULL DEFAULT '',
+		error TEXT
+	);
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return time.Time{}, err
+	CREATE TABLE IF NOT EXISTS feedback_issues (
+		assignment_id INTEGER NOT NULL,
+		username TEXT NOT NULL,
+		issue_number INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		body TEXT NOT NULL,
+		posted_at DATETIME NOT NULL,
+		FOREIGN KEY(assignment_id) REFERENCES assignments(id),
+		UNIQUE(assignment_id, username)
+	);`
+
+	if _, err = db.Exec(createTable); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return time.Time{}, fmt.Errorf("GitHub API returned status: %s", resp.Status)
+	// Columns added after the first release; older databases need them
+	// added in place.
+	migrations := []struct{ table, column, definition string }{
+		{"students", "verified", "INTEGER NOT NULL DEFAULT 1"},
+		{"classes", "archived", "INTEGER NOT NULL DEFAULT 0"},
+		{"students", "archived", "INTEGER NOT NULL DEFAULT 0"},
+		{"assignments", "starter_repo", "TEXT NOT NULL DEFAULT ''"},
+		{"assignments", "starter_ref", "TEXT NOT NULL DEFAULT ''"},
+		{"assignments", "due_at", "DATETIME"},
+		{"assignments", "check_command", "TEXT NOT NULL DEFAULT ''"},
+		{"check_results", "limit_hit", "TEXT NOT NULL DEFAULT ''"},
+		{"classes", "pull_policy", "TEXT NOT NULL DEFAULT 'skip'"},
+		{"classes", "clone_depth", "INTEGER NOT NULL DEFAULT 0"},
+		{"classes", "clone_filter", "TEXT NOT NULL DEFAULT ''"},
+		{"classes", "clone_sparse", "TEXT NOT NULL DEFAULT ''"},
+		{"classes", "clone_single_branch", "INTEGER NOT NULL DEFAULT 0"},
+		{"assignments", "clone_depth", "INTEGER NOT NULL DEFAULT 0"},
+		{"assignments", "clone_filter", "TEXT NOT NULL DEFAULT ''"},
+		{"assignments", "clone_sparse", "TEXT NOT NULL DEFAULT ''"},
+		{"assignments", "clone_single_branch", "INTEGER NOT NULL DEFAULT 0"},
+		{"assignments", "assigned_at", "DATETIME"},
+		{"classes", "repo_pattern", "TEXT NOT NULL DEFAULT '{user}.github.io'"},
+		{"repo_health", "repo", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, mig := range migrations {
+		if err := addColumnIfMissing(mig.table, mig.column, mig.definition); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	var events []GithubEvent
-	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
-		return time.Time{}, err
+func addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
 	}
+	defer rows.Close()
 
-	var lastPushTime time.Time
-	for _, event := range events {
-		if event.Type == "PushEvent" {
-			if lastPushTime.IsZero() || event.CreatedAt.After(lastPushTime) {
-				lastPushTime = event.CreatedAt
-			}
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
 		}
 	}
-
-	if lastPushTime.IsZero() {
-		return time.Time{}, fmt.Errorf("no push events found")
+	if err := rows.Err(); err != nil {
+		return err
 	}
 
-	return lastPushTime, nil
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
 }
 
 func formatDuration(d time.Duration) string {
@@ -226,65 +415,50 @@ func centerText(s string, width int) string {
 	return strings.Repeat(" ", leftPad) + s + strings.Repeat(" ", rightPad)
 }
 
+// getUserPushDates refreshes a user's activity and returns the push days in
+// range from the stored history, so days GitHub no longer reports still show.
+// On a fetch error the stored days are returned along with the error.
 func getUserPushDates(username string, start, end time.Time) (map[string]bool, error) {
-	token := os.Getenv("GITHUB_TOKEN")
-	if token == "" {
-		return nil, fmt.Errorf("GITHUB_TOKEN environment variable not set")
-	}
-
-	url := fmt.Sprintf("https://api.github.com/users/%s/events/public", username)
-	req, err := http.NewRequest("GET", url, nil)
+	fetchErr := refreshActivity(username)
+	pushDates, err := storedPushDates(username, start, end)
 	if err != nil {
 		return nil, err
 	}
-
-	req.Header.Set("Authorization", "token "+token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GitHub API returned status: %s", resp.Status)
-	}
-
-	var events []GithubEvent
-	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
-		return nil, err
-	}
-
-	// Create map of dates with pushes
-	pushDates := make(map[string]bool)
-	for _, event := range events {
-		if event.Type == "PushEvent" {
-			date := event.CreatedAt.Format("2006-01-02")
-			if event.CreatedAt.After(start) && event.CreatedAt.Before(end.AddDate(0, 0, 1)) {
-				pushDates[date] = true
-			}
-		}
-	}
-
-	return pushDates, nil
+	return pushDates, fetchErr
 }
 
 func initialModel() model {
 	// Create main menu items
 	items := []list.Item{
 		item{title: "Add Class", description: "Create a new class"},
-		item{title: "Remove Class", description: "Remove a class and its students"},
+		item{title: "Remove Class", description: "Archive a class and its students"},
+		item{title: "Restore Class", description: "Restore an archived class"},
+		item{title: "Delete Class", description: "Permanently delete a class and its students"},
 		item{title: "List Classes", description: "Show all classes"},
 		item{title: "Add Students", description: "Add students to a class"},
-		item{title: "Remove Students", description: "Remove students from a class"},
+		item{title: "Remove Students", description: "Archive students in a class"},
+		item{title: "Restore Students", description: "Restore archived students"},
+		item{title: "Move Students", description: "Move students to another class"},
+		item{title: "Copy Students", description: "Copy students into another class"},
 		item{title: "List Students", description: "Show all students in a class"},
 		item{title: "Clone Repositories", description: "Clone all student repositories"},
 		item{title: "Pull Changes", description: "Update all repositories"},
 		item{title: "Clean Changes", description: "Revert local changes"},
 		item{title: "Check Activity", description: "View recent student activity"},
 		item{title: "Week History", description: "Show weekly activity grid"},
+		item{title: "Browse Code", description: "Browse students' cloned repositories"},
+		item{title: "Diff vs Starter", description: "Compare student work with an assignment's starter"},
+		item{title: "Similarity Check", description: "Find submissions that share code"},
+		item{title: "Deadline Snapshot", description: "Tag each student's on-time work for an assignment"},
+		item{title: "Submission Status", description: "See who is late or hasn't started an assignment"},
+		item{title: "Run Checks", description: "Run an assignment's tests in every repository"},
+		item{title: "Grade Assignment", description: "Score students against an assignment's rubric"},
+		item{title: "Repo Health", description: "Check repositories, Pages builds and local clones"},
+		item{title: "Lint Sites", description: "Find broken links and HTML/CSS errors in Pages sites"},
+		item{title: "Activity Trends", description: "Show streaks and weekly participation"},
+		item{title: "Progress Report", description: "Save a Markdown progress report for a class"},
+		item{title: "List Archived", description: "Show archived classes and students"},
+		item{title: "Sync Status", description: "Show when the sync daemon last ran"},
 		item{title: "Quit", description: "Exit the application"},
 	}
 
@@ -306,29 +480,32 @@ func initialModel() model {
 	studentInput.Placeholder = "Enter student usernames (space-separated)"
 	studentInput.Focus()
 
+	targetInput := textinput.New()
+	targetInput.Placeholder = "Enter target class name"
+	targetInput.Focus()
+
+	assignmentInput := textinput.New()
+	assignmentInput.Placeholder = "Enter assignment name"
+	assignmentInput.Focus()
+
 	return model{
-		list:         l,
-		state:        stateMainMenu,
-		classInput:   classInput,
-		studentInput: studentInput,
-		output:       "",
+		list:            l,
+		state:           stateMainMenu,
+		classInput:      classInput,
+		studentInput:    studentInput,
+		targetInput:     targetInput,
+		assignmentInput: assignmentInput,
+		output:          "",
 	}
 }
 
 // showWeekHistoryTview builds and runs a tview application that displays the weekly activity grid.
 func showWeekHistoryTview(className string) error {
 	start, end := getGridDateRange()
-	rows, err := db.Query(`
-		SELECT s.username 
-		FROM students s
-		JOIN classes c ON s.class_id = c.id
-		WHERE c.name = ?
-		ORDER BY s.username`,
-		className)
+	usernames, err := classStudents(className)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
 
 	// Create a tview table.
 	table := tview.NewTable().SetBorders(true)
@@ -350,21 +527,16 @@ func showWeekHistoryTview(className string) error {
 
 	// Fill in rows with student activity.
 	rowIndex := 1
-	for rows.Next() {
-		var username string
-		if err := rows.Scan(&username); err != nil {
-			return err
-		}
-
+	for _, username := range usernames {
 		table.SetCell(rowIndex, 0, tview.NewTableCell(username).
 			SetTextColor(tcell.ColorWhite).
 			SetAlign(tview.AlignCenter))
 
 		col = 1
-		pushDates, err := getUserPushDates(username, start, end)
+		pushDates, _ := getUserPushDates(username, start, end)
 		for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
 			dateKey := d.Format("2006-01-02")
-			if err == nil && pushDates[dateKey] {
+			if pushDates[dateKey] {
 				table.SetCell(rowIndex, col, tview.NewTableCell("✓").
 					SetTextColor(tcell.ColorGreen).
 					SetAlign(tview.AlignCenter))
@@ -403,6 +575,13 @@ func showWeekHistoryTview(className string) error {
 
 	// Run the tview application.
 	err = app.SetRoot(flex, true).Run()
+	waitForTerminal()
+	return err
+}
+
+// waitForTerminal gives the terminal time to restore its state after a
+// tview screen exits, before bubbletea redraws the menu.
+func waitForTerminal() {
 	// Show a loading animation while the terminal restores
 	spinner := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 	for i := 0; i < 10; i++ {
@@ -413,7 +592,6 @@ func showWeekHistoryTview(className string) error {
 	time.Sleep(1000 * time.Millisecond)
 
 	fmt.Print("\r") // Clear the line
-	return err
 }
 
 func (m model) Init() tea.Cmd {
@@ -431,6 +609,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	if m.state == stateRoster {
+		return m.updateRoster(msg)
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
@@ -444,41 +626,62 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					switch i.title {
 					case "Quit":
 						return m, tea.Quit
-					case "Add Class", "Remove Class", "List Students", "Clone Repositories",
-						"Pull Changes", "Clean Changes", "Check Activity", "Week History":
+					case "Add Class", "Remove Class", "Restore Class", "Delete Class", "List Students",
+						"Clone Repositories", "Pull Changes", "Clean Changes", "Check Activity", "Week History", "Activity Trends",
+						"Progress Report", "Browse Code",
+						"Diff vs Starter", "Similarity Check", "Deadline Snapshot", "Submission Status",
+						"Run Checks", "Grade Assignment", "Repo Health", "Lint Sites":
 						m.state = stateClassInput
 						return m, nil
-					case "Add Students":
+					case "Add Students", "Remove Students", "Restore Students", "Move Students", "Copy Students":
 						m.state = stateClassInput
 						m.className = ""
 						return m, nil
 					case "List Classes":
-						rows, err := db.Query("SELECT name FROM classes")
+						classes, err := activeClasses()
 						if err != nil {
 							m.err = err
 							return m, nil
 						}
-						defer rows.Close()
 
 						var sb strings.Builder
 						sb.WriteString("Classes:\n")
-						for rows.Next() {
-							var name string
-							rows.Scan(&name)
+						for _, name := range classes {
 							sb.WriteString(fmt.Sprintf("- %s\n", name))
 						}
 						m.output = sb.String()
 						m.state = stateOutput
 						return m, nil
+					case "List Archived", "Sync Status":
+						report := archivedSummary
+						if i.title == "Sync Status" {
+							report = syncStatus
+						}
+						out, err := report()
+						if err != nil {
+							m.err = err
+							return m, nil
+						}
+						m.output = out
+						m.state = stateOutput
+						return m, nil
 					}
 				}
 			} else if m.state == stateClassInput {
 				m.className = m.classInput.Value()
 				i, _ := m.list.SelectedItem().(item)
 
-				if i.title == "Add Students" {
+				switch i.title {
+				case "Add Students", "Remove Students", "Restore Students", "Move Students", "Copy Students":
 					m.state = stateStudentInput
 					return m, nil
+				case "Delete Class":
+					m.state = stateConfirmDelete
+					return m, nil
+				case "Diff vs Starter", "Similarity Check", "Deadline Snapshot", "Submission Status", "Run Checks",
+					"Grade Assignment", "Browse Code":
+					m.state = stateAssignmentInput
+					return m, nil
 				}
 
 				switch i.title {
@@ -492,229 +695,122 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.state = stateOutput
 					return m, nil
 
-				case "Remove Class":
-					tx, err := db.Begin()
+				case "Remove Class", "Restore Class":
+					out, err := setClassArchived(m.className, i.title == "Remove Class")
 					if err != nil {
 						m.err = err
 						return m, nil
 					}
-					defer tx.Rollback()
+					m.output = out
+					m.state = stateOutput
+					return m, nil
 
-					var classID int
-					err = tx.QueryRow("SELECT id FROM classes WHERE name = ?", m.className).Scan(&classID)
+				case "List Students":
+					roster, err := classRoster(m.className)
 					if err != nil {
-						m.err = fmt.Errorf("class not found: %s", m.className)
+						m.err = fmt.Errorf("failed to query students: %v", err)
 						return m, nil
 					}
-
-					_, err = tx.Exec("DELETE FROM students WHERE class_id = ?", classID)
+					repo, err := classRepoPattern(m.className)
 					if err != nil {
-						m.err = fmt.Errorf("failed to remove students: %v", err)
+						m.err = err
 						return m, nil
 					}
 
-					_, err = tx.Exec("DELETE FROM classes WHERE id = ?", classID)
+					m.roster = newRosterList(fmt.Sprintf("Students in %s", m.className), rosterItems(roster, repo))
+					m.state = stateRoster
+					return m, nil
+
+				case "Clone Repositories":
+					output, err := cloneClass(m.className, "")
 					if err != nil {
-						m.err = fmt.Errorf("failed to remove class: %v", err)
+						m.err = err
 						return m, nil
 					}
+					m.output = output
+					m.state = stateOutput
+					return m, nil
 
-					if err := tx.Commit(); err != nil {
-						m.err = fmt.Errorf("failed to commit changes: %v", err)
+				case "Pull Changes":
+					results, policy, err := pullClass(m.className, "", "", false)
+					if err != nil {
+						m.err = err
 						return m, nil
 					}
-
-					m.output = fmt.Sprintf("Removed class: %s and all its students\n", m.className)
+					m.output = pullText(m.className, policy, results, false)
 					m.state = stateOutput
 					return m, nil
 
-				case "List Students":
-					rows, err := db.Query(`
-						SELECT s.username 
-						FROM students s
-						JOIN classes c ON s.class_id = c.id
-						WHERE c.name = ?
-						ORDER BY s.username`,
-						m.className)
+				case "Clean Changes":
+					usernames, err := classStudents(m.className)
 					if err != nil {
 						m.err = fmt.Errorf("failed to query students: %v", err)
 						return m, nil
 					}
-					defer rows.Close()
 
 					var sb strings.Builder
-					sb.WriteString(fmt.Sprintf("Students in %s:\n", m.className))
-					for rows.Next() {
-						var username string
-						if err := rows.Scan(&username); err != nil {
-							m.err = err
-							return m, nil
+					for _, username := range usernames {
+						if isCloned(username) {
+							if err := vcs.Discard(username); err != nil {
+								sb.WriteString(fmt.Sprintf("Failed to clean repository for %s: %v\n", username, err))
+								continue
+							}
+							sb.WriteString(fmt.Sprintf("Cleaned repository for: %s\n", username))
 						}
-						sb.WriteString(fmt.Sprintf("- %s\n", username))
 					}
 					m.output = sb.String()
 					m.state = stateOutput
 					return m, nil
 
-				case "Clone Repositories":
-					rows, err := db.Query(`
-						SELECT s.username 
-						FROM students s
-						JOIN classes c ON s.class_id = c.id
-						WHERE c.name = ?`,
-						m.className)
+				case "Check Activity":
+					activity, err := classActivity(m.className, true)
 					if err != nil {
 						m.err = fmt.Errorf("failed to query students: %v", err)
 						return m, nil
 					}
-					defer rows.Close()
 
-					var sb strings.Builder
-					for rows.Next() {
-						var username string
-						if err := rows.Scan(&username); err != nil {
-							m.err = err
-							return m, nil
-						}
+					m.roster = newRosterList(fmt.Sprintf("Activity Report for %s", m.className), activityItems(activity))
+					m.state = stateRoster
+					return m, nil
 
-						cmd := exec.Command("git", "clone", fmt.Sprintf("https://github.com/%s/%s.github.io", username, username), username)
-						if err := cmd.Run(); err != nil {
-							sb.WriteString(fmt.Sprintf("Failed to clone repository for %s: %v\n", username, err))
-							continue
-						}
-						sb.WriteString(fmt.Sprintf("Cloned repository for: %s\n", username))
+				case "Progress Report":
+					to := time.Now()
+					out, err := generateReport(m.className, "", to.AddDate(0, 0, -defaultReportDays), to, formatMarkdown, "reports")
+					if err != nil {
+						m.err = err
+						return m, nil
 					}
-					m.output = sb.String()
+					m.output = out
 					m.state = stateOutput
 					return m, nil
 
-				case "Pull Changes":
-					rows, err := db.Query(`
-						SELECT s.username 
-						FROM students s
-						JOIN classes c ON s.class_id = c.id
-						WHERE c.name = ?`,
-						m.className)
+				case "Activity Trends":
+					out, err := trendReport(m.className, defaultTrendWeeks)
 					if err != nil {
-						m.err = fmt.Errorf("failed to query students: %v", err)
+						m.err = err
 						return m, nil
 					}
-					defer rows.Close()
-
-					var sb strings.Builder
-					for rows.Next() {
-						var username string
-						if err := rows.Scan(&username); err != nil {
-							m.err = err
-							return m, nil
-						}
-
-						if _, err := os.Stat(username); err == nil {
-							cmd := exec.Command("git", "-C", username, "pull")
-							if err := cmd.Run(); err != nil {
-								sb.WriteString(fmt.Sprintf("Failed to pull repository for %s: %v\n", username, err))
-								continue
-							}
-							sb.WriteString(fmt.Sprintf("Pulled latest changes for: %s\n", username))
-						}
-					}
-					m.output = sb.String()
+					m.output = out
 					m.state = stateOutput
 					return m, nil
 
-				case "Clean Changes":
-					rows, err := db.Query(`
-						SELECT s.username 
-						FROM students s
-						JOIN classes c ON s.class_id = c.id
-						WHERE c.name = ?`,
-						m.className)
+				case "Repo Health":
+					result, err := classRepoHealth(m.className, false)
 					if err != nil {
-						m.err = fmt.Errorf("failed to query students: %v", err)
+						m.err = err
 						return m, nil
 					}
-					defer rows.Close()
-
-					var sb strings.Builder
-					for rows.Next() {
-						var username string
-						if err := rows.Scan(&username); err != nil {
-							m.err = err
-							return m, nil
-						}
-
-						if _, err := os.Stat(username); err == nil {
-							cmd := exec.Command("git", "-C", username, "checkout", ".")
-							if err := cmd.Run(); err != nil {
-								sb.WriteString(fmt.Sprintf("Failed to clean repository for %s: %v\n", username, err))
-								continue
-							}
-							sb.WriteString(fmt.Sprintf("Cleaned repository for: %s\n", username))
-						}
-					}
-					m.output = sb.String()
+					m.output = repoHealthText(m.className, result)
 					m.state = stateOutput
 					return m, nil
 
-				case "Check Activity":
-					rows, err := db.Query(`
-						SELECT s.username 
-						FROM students s
-						JOIN classes c ON s.class_id = c.id
-						WHERE c.name = ?
-						ORDER BY s.username`,
-						m.className)
+				case "Lint Sites":
+					results, err := lintClass(m.className)
 					if err != nil {
-						m.err = fmt.Errorf("failed to query students: %v", err)
+						m.err = err
 						return m, nil
 					}
-					defer rows.Close()
-
-					var sb strings.Builder
-					sb.WriteString(fmt.Sprintf("Activity Report for %s:\n", m.className))
-					sb.WriteString("----------------------------------------\n")
-
-					for rows.Next() {
-						var username string
-						if err := rows.Scan(&username); err != nil {
-							m.err = err
-							return m, nil
-						}
-
-						lastPush, err := getLastPushTime(username)
-						if err != nil {
-							sb.WriteString(fmt.Sprintf("%s %s: Error checking activity - %v\n",
-								errorStyle.Render("❌"),
-								errorStyle.Render(username),
-								err,
-							))
-							continue
-						}
-
-						timeSince := time.Since(lastPush)
-						switch {
-						case timeSince < 24*time.Hour:
-							sb.WriteString(fmt.Sprintf("%s %s: Last push %s ago\n",
-								successStyle.Render(iconSuccess),
-								successStyle.Render(username),
-								formatDuration(timeSince),
-							))
-						case timeSince < 72*time.Hour:
-							sb.WriteString(fmt.Sprintf("%s %s: Last push %s ago\n",
-								warningStyle.Render(iconWarning),
-								warningStyle.Render(username),
-								formatDuration(timeSince),
-							))
-						default:
-							sb.WriteString(fmt.Sprintf("%s %s: Last push %s ago\n",
-								errorStyle.Render(iconError),
-								errorStyle.Render(username),
-								formatDuration(timeSince),
-							))
-						}
-					}
-
-					m.output = sb.String()
+					m.output = lintText(m.className, results)
 					m.state = stateOutput
 					return m, nil
 
@@ -731,26 +827,130 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return m, tea.Quit
 			} else if m.state == stateStudentInput {
-				usernames := strings.Fields(m.studentInput.Value())
+				i, _ := m.list.SelectedItem().(item)
+				switch i.title {
+				case "Move Students", "Copy Students":
+					m.state = stateTargetClassInput
+					return m, nil
+				case "Remove Students", "Restore Students":
+					out, err := setStudentsArchived(m.className, strings.Fields(m.studentInput.Value()), i.title == "Remove Students")
+					if err != nil {
+						m.err = err
+						return m, nil
+					}
+					m.output = out
+					m.state = stateOutput
+					return m, nil
+				}
 
-				var classID int
-				err := db.QueryRow("SELECT id FROM classes WHERE name = ?", m.className).Scan(&classID)
+				usernames := strings.Fields(m.studentInput.Value())
+				repo, err := classRepoPattern(m.className)
 				if err != nil {
-					m.err = fmt.Errorf("class not found: %s", m.className)
+					m.err = err
 					return m, nil
 				}
 
-				var sb strings.Builder
-				for _, username := range usernames {
-					_, err := db.Exec("INSERT OR IGNORE INTO students (username, class_id) VALUES (?, ?)",
-						username, classID)
+				checks := validateStudents(usernames, repo)
+				for _, c := range checks {
+					if !c.valid() {
+						// Let the teacher decide what to do with the bad entries.
+						m.checks = checks
+						m.state = stateConfirmUnverified
+						return m, nil
+					}
+				}
+
+				out, err := addStudents(m.className, checks, false)
+				if err != nil {
+					m.err = err
+					return m, nil
+				}
+				m.output = out
+				m.state = stateOutput
+				return m, nil
+			} else if m.state == stateAssignmentInput {
+				assignmentName := strings.TrimSpace(m.assignmentInput.Value())
+				i, _ := m.list.SelectedItem().(item)
+				switch i.title {
+				case "Similarity Check", "Deadline Snapshot", "Submission Status", "Run Checks":
+					var out string
+					var err error
+					switch i.title {
+					case "Similarity Check":
+						out, err = similarityReport(m.className, assignmentName, defaultSimilarityThreshold)
+					case "Deadline Snapshot":
+						out, err = snapshotDeadline(m.className, assignmentName)
+					case "Run Checks":
+						out, err = runChecks(m.className, assignmentName, defaultSandboxLimits)
+					default:
+						var a assignment
+						var subs []submission
+						a, subs, err = classSubmissions(m.className, assignmentName)
+						out = submissionText(a, subs)
+					}
 					if err != nil {
 						m.err = err
 						return m, nil
 					}
-					sb.WriteString(fmt.Sprintf("Added student: %s to class: %s\n", username, m.className))
+					m.output = out
+					m.state = stateOutput
+					return m, nil
+				}
+
+				view := showDiffViewer
+				switch i.title {
+				case "Grade Assignment":
+					view = showGradingScreen
+				case "Browse Code":
+					view = func(className, assignmentName string) error {
+						return showCodeBrowser(className, "", assignmentName)
+					}
+				}
+				err := view(m.className, assignmentName)
+				waitForTerminal()
+				if err != nil {
+					m.err = err
+				} else {
+					m.output = fmt.Sprintf("Returned from %s.", i.title)
 				}
-				m.output = sb.String()
+				m.state = stateOutput
+				return m, nil
+			} else if m.state == stateTargetClassInput {
+				i, _ := m.list.SelectedItem().(item)
+				usernames := strings.Fields(m.studentInput.Value())
+				out, err := transferStudents(usernames, m.className, m.targetInput.Value(), i.title == "Copy Students")
+				if err != nil {
+					m.err = err
+					return m, nil
+				}
+				m.output = out
+				m.state = stateOutput
+				return m, nil
+			}
+
+		case "y", "n":
+			if m.state == stateConfirmUnverified {
+				out, err := addStudents(m.className, m.checks, msg.String() == "y")
+				m.checks = nil
+				if err != nil {
+					m.err = err
+					return m, nil
+				}
+				m.output = out
+				m.state = stateOutput
+				return m, nil
+			}
+			if m.state == stateConfirmDelete {
+				if msg.String() == "n" {
+					m.state = stateMainMenu
+					return m, nil
+				}
+				out, err := deleteClass(m.className)
+				if err != nil {
+					m.err = err
+					return m, nil
+				}
+				m.output = out
 				m.state = stateOutput
 				return m, nil
 			}
@@ -765,6 +965,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.classInput, cmd = m.classInput.Update(msg)
 	case stateStudentInput:
 		m.studentInput, cmd = m.studentInput.Update(msg)
+	case stateTargetClassInput:
+		m.targetInput, cmd = m.targetInput.Update(msg)
+	case stateAssignmentInput:
+		m.assignmentInput, cmd = m.assignmentInput.Update(msg)
 	}
 
 	return m, cmd
@@ -785,6 +989,37 @@ func (m model) View() string {
 				"(Space-separated list of GitHub usernames)\n\n" +
 				m.studentInput.View(),
 		)
+	case stateTargetClassInput:
+		return docStyle.Render(
+			titleStyle.Render("Enter Target Class Name") + "\n\n" +
+				m.targetInput.View(),
+		)
+	case stateAssignmentInput:
+		hint := "\n"
+		switch i, _ := m.list.SelectedItem().(item); i.title {
+		case "Similarity Check":
+			hint = "\n(Its starter code, if it has one, is ignored; leave empty to compare everything)\n"
+		case "Browse Code":
+			hint = "\n(Review notes are filed under it for scv send-feedback; leave empty to only browse)\n"
+		}
+		return docStyle.Render(
+			titleStyle.Render("Enter Assignment Name") + "\n" + hint +
+				m.assignmentInput.View(),
+		)
+	case stateConfirmUnverified:
+		return docStyle.Render(
+			titleStyle.Render("Validate Students") + "\n\n" +
+				validationReport(m.checks) + "\n" +
+				"Add these students anyway, flagged as unverified? (y/n)",
+		)
+	case stateConfirmDelete:
+		return docStyle.Render(
+			titleStyle.Render("Delete Class") + "\n\n" +
+				errorStyle.Render(fmt.Sprintf("Permanently delete %s and all its students?", m.className)) + "\n" +
+				"This cannot be undone. Use Remove Class to archive instead. (y/n)",
+		)
+	case stateRoster:
+		return docStyle.Render(m.roster.View())
 	case stateOutput:
 		return docStyle.Render(
 			outputBoxStyle.Render(m.output + "\n\nPress Enter/Esc to go back."),
@@ -795,8 +1030,12 @@ func (m model) View() string {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == sandboxExecArg {
+		runSandboxExec(os.Args[2:])
+	}
+
 	var err error
-	db, err = sql.Open("sqlite3", "./students.db")
+	db, err = sql.Open("sqlite3", dbDSN)
 	if err != nil {
 		fmt.Printf("Failed to open database: %v\n", err)
 		os.Exit(1)
@@ -808,9 +1047,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	p := tea.NewProgram(initialModel())
-	if _, err := p.Run(); err != nil {
-		fmt.Printf("Error running program: %v\n", err)
+	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
 }
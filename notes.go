@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// note is a free-form teacher note about a student. Notes are keyed by
+// username so they follow a student between classes.
+type note struct {
+	CreatedAt time.Time `json:"created_at"`
+	Body      string    `json:"body"`
+}
+
+func addNote(username, body string) (string, error) {
+	if strings.TrimSpace(body) == "" {
+		return "", fmt.Errorf("note is empty")
+	}
+	_, err := db.Exec("INSERT INTO notes (username, created_at, body) VALUES (?, ?, ?)",
+		username, time.Now().UTC(), body)
+	if err != nil {
+		return "", fmt.Errorf("failed to add note: %v", err)
+	}
+	return fmt.Sprintf("Added note for: %s\n", username), nil
+}
+
+// studentNotes returns a student's notes written in [from, to], oldest first.
+func studentNotes(username string, from, to time.Time) ([]note, error) {
+	rows, err := db.Query(`
+		SELECT created_at, body FROM notes
+		WHERE username = ? AND created_at >= ? AND created_at < ?
+		ORDER BY created_at`,
+		username, dayStart(from), dayStart(to).AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []note
+	for rows.Next() {
+		var n note
+		if err := rows.Scan(&n.CreatedAt, &n.Body); err != nil {
+			return nil, err
+		}
+		result = append(result, n)
+	}
+	return result, rows.Err()
+}
+
+func listNotes(username string) (string, error) {
+	notes, err := studentNotes(username, time.Time{}, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Notes for %s:\n", username))
+	for _, n := range notes {
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", n.CreatedAt.Local().Format("2006-01-02"), n.Body))
+	}
+	return sb.String(), nil
+}
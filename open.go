@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// url is the GitHub page of the repository Clone Repositories uses.
+func (p repoPattern) url(username string) string {
+	return fmt.Sprintf("https://github.com/%s/%s", username, p.name(username))
+}
+
+// pagesURL is where GitHub Pages deploys the student's site: the root of
+// their github.io domain for a user site, a folder of it for any other
+// repository.
+func (p repoPattern) pagesURL(username string) string {
+	name := p.name(username)
+	if strings.EqualFold(name, username+".github.io") {
+		return "https://" + name
+	}
+	return fmt.Sprintf("https://%s.github.io/%s/", username, name)
+}
+
+// openURL opens a URL in the default browser without waiting for it.
+func openURL(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
+// editorCommand builds the command that opens a student's clone in
+// $VISUAL or $EDITOR (falling back to vi). The variable may include
+// arguments, e.g. "code -w".
+func editorCommand(username string) (*exec.Cmd, error) {
+	if !isCloned(username) {
+		return nil, fmt.Errorf("no local clone for %s; run Clone Repositories first", username)
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], username)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd, nil
+}
+
+// rosterKeys are the actions available on a selected student.
+var rosterKeys = struct {
+	editor, repo, pages, urls key.Binding
+}{
+	editor: key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "open in $EDITOR")),
+	repo:   key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "GitHub repo")),
+	pages:  key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "Pages site")),
+	urls:   key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "show URLs")),
+}
+
+// editorFinishedMsg is sent when the editor launched from the roster exits.
+type editorFinishedMsg struct{ err error }
+
+// newRosterList builds the selectable student list used by List Students
+// and Check Activity. Each item's title is the username.
+func newRosterList(title string, items []list.Item) list.Model {
+	l := list.New(items, list.NewDefaultDelegate(), 70, 20)
+	l.Title = title
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.Styles.Title = titleStyle
+	l.Styles.PaginationStyle = paginationStyle
+	l.Styles.HelpStyle = helpStyle
+	l.StatusMessageLifetime = 10 * time.Second
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{rosterKeys.editor, rosterKeys.repo, rosterKeys.pages, rosterKeys.urls}
+	}
+	return l
+}
+
+func rosterItems(roster []student, repo repoPattern) []list.Item {
+	var items []list.Item
+	for _, st := range roster {
+		desc := repo.name(st.Username)
+		if !st.Verified {
+			desc += " " + warningStyle.Render("(unverified)")
+		}
+		items = append(items, item{title: st.Username, description: desc})
+	}
+	return items
+}
+
+func activityItems(activity []studentActivity) []list.Item {
+	var items []list.Item
+	for _, a := range activity {
+		var desc string
+		switch a.Status {
+		case statusError:
+			desc = errorStyle.Render("❌") + " Error checking activity - " + a.Error
+		case statusActive:
+			desc = successStyle.Render(iconSuccess) + " Last push " + formatDuration(time.Since(a.LastPush)) + " ago"
+		case statusRecent:
+			desc = warningStyle.Render(iconWarning) + " Last push " + formatDuration(time.Since(a.LastPush)) + " ago"
+		default:
+			desc = errorStyle.Render(iconError) + " Last push " + formatDuration(time.Since(a.LastPush)) + " ago"
+		}
+		items = append(items, item{title: a.Username, description: desc})
+	}
+	return items
+}
+
+// updateRoster handles keys while a roster list is shown.
+func (m model) updateRoster(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case editorFinishedMsg:
+		if msg.err != nil {
+			return m, m.roster.NewStatusMessage(errorStyle.Render(fmt.Sprintf("Editor failed: %v", msg.err)))
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		selected, _ := m.roster.SelectedItem().(item)
+		username := selected.title
+
+		switch {
+		case msg.String() == "enter" || msg.String() == "esc":
+			m.state = stateMainMenu
+			return m, nil
+
+		case msg.String() == "ctrl+c" || msg.String() == "q":
+			return m, tea.Quit
+
+		case username == "":
+			return m, nil
+
+		case key.Matches(msg, rosterKeys.editor):
+			cmd, err := editorCommand(username)
+			if err != nil {
+				return m, m.roster.NewStatusMessage(errorStyle.Render(err.Error()))
+			}
+			return m, tea.ExecProcess(cmd, func(err error) tea.Msg { return editorFinishedMsg{err} })
+
+		case key.Matches(msg, rosterKeys.repo), key.Matches(msg, rosterKeys.pages):
+			repo, err := classRepoPattern(m.className)
+			if err != nil {
+				return m, m.roster.NewStatusMessage(errorStyle.Render(err.Error()))
+			}
+			url := repo.url(username)
+			if key.Matches(msg, rosterKeys.pages) {
+				url = repo.pagesURL(username)
+			}
+			if err := openURL(url); err != nil {
+				return m, m.roster.NewStatusMessage(errorStyle.Render(fmt.Sprintf("Could not open %s: %v", url, err)))
+			}
+			return m, m.roster.NewStatusMessage("Opened " + url)
+
+		case key.Matches(msg, rosterKeys.urls):
+			repo, err := classRepoPattern(m.className)
+			if err != nil {
+				return m, m.roster.NewStatusMessage(errorStyle.Render(err.Error()))
+			}
+			return m, m.roster.NewStatusMessage(repo.url(username) + "  " + repo.pagesURL(username))
+		}
+	}
+
+	var cmd tea.Cmd
+	m.roster, cmd = m.roster.Update(msg)
+	return m, cmd
+}
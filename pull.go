@@ -0,0 +1,375 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pull policies decide what Pull Changes does with a clone that can't
+// simply be fast-forwarded.
+const (
+	pullSkip    = "skip"    // leave it alone and report why
+	pullStash   = "stash"   // stash local changes, then pull
+	pullReset   = "reset"   // discard local work and match GitHub
+	pullReclone = "reclone" // delete the clone and clone again
+)
+
+var pullPolicies = []string{pullSkip, pullStash, pullReset, pullReclone}
+
+// Outcomes of pulling one clone.
+const (
+	pullPulled    = "pulled"
+	pullUpToDate  = "up_to_date"
+	pullSkipped   = "skipped"
+	pullStashed   = "stashed"
+	pullWasReset  = "reset"
+	pullRecloned  = "recloned"
+	pullNotCloned = "not_cloned"
+	pullFailed    = "error"
+)
+
+// cloneInspection is the state of a clone after fetching, before anything
+// is changed.
+type cloneInspection struct {
+	Branch      string   `json:"branch"`          // empty when detached
+	Upstream    string   `json:"upstream"`        // what the clone pulls from, e.g. origin/main
+	Dirty       []string `json:"dirty,omitempty"` // git status --porcelain lines
+	Ahead       int      `json:"ahead"`           // local commits GitHub doesn't have
+	Behind      int      `json:"behind"`          // GitHub commits not yet pulled
+	RemoteURL   string   `json:"remote_url"`
+	URLMismatch bool     `json:"url_mismatch"`
+}
+
+func (c cloneInspection) detached() bool {
+	return c.Branch == ""
+}
+
+// problems lists what stops a plain fast-forward.
+func (c cloneInspection) problems() []string {
+	var problems []string
+	if c.URLMismatch {
+		problems = append(problems, "origin is "+c.RemoteURL)
+	}
+	if c.detached() {
+		problems = append(problems, "detached HEAD")
+	}
+	if len(c.Dirty) > 0 {
+		problems = append(problems, fmt.Sprintf("uncommitted changes: %d", len(c.Dirty)))
+	}
+	if c.Ahead > 0 {
+		problems = append(problems, fmt.Sprintf("diverged: %d local, %d on GitHub", c.Ahead, c.Behind))
+	}
+	return problems
+}
+
+// pullResult is what Pull Changes did to one student's clone.
+type pullResult struct {
+	Username string `json:"username"`
+	Action   string `json:"action"`
+	Detail   string `json:"detail,omitempty"`
+	cloneInspection
+}
+
+// errCloneUnreadable is returned by inspectClone for a clone whose status
+// can't be read at all, as opposed to one that couldn't be fetched.
+var errCloneUnreadable = errors.New("clone is unreadable")
+
+// inspectClone fetches a clone and describes its state. A clone whose
+// origin isn't the student's repository isn't fetched.
+func inspectClone(username string, repo repoPattern) (cloneInspection, error) {
+	var c cloneInspection
+	s, err := vcs.Status(username)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", errCloneUnreadable, err)
+	}
+	c.Branch, c.Dirty, c.RemoteURL = s.Branch, s.Dirty, s.RemoteURL
+	if c.URLMismatch = !sameRepoURL(c.RemoteURL, repo.url(username)); c.URLMismatch {
+		return c, nil
+	}
+
+	if err := vcs.Fetch(username); err != nil {
+		return c, err
+	}
+	d, err := vcs.Divergence(username)
+	if err != nil {
+		return c, err
+	}
+	c.Upstream, c.Ahead, c.Behind = d.Upstream, d.Ahead, d.Behind
+	return c, nil
+}
+
+// resetClone points the clone back at the student's repository and makes
+// it match GitHub exactly, discarding local commits and changes.
+func resetClone(username string, repo repoPattern) error {
+	if err := vcs.SetRemoteURL(username, repo.url(username)); err != nil {
+		return err
+	}
+	if err := vcs.Fetch(username); err != nil {
+		return err
+	}
+	d, err := vcs.Divergence(username)
+	if err != nil {
+		return err
+	}
+	return vcs.Reset(username, d.Upstream)
+}
+
+// recloneRepository clones the student's repository next to the existing
+// clone and swaps it in only once the clone has succeeded, so a failed
+// clone leaves the old one where it was. Deadline tags are copied across,
+// and a clone with stashes, or whose stashes can't be read, is moved under
+// .scv/recloned instead of being deleted; the returned path says where.
+func recloneRepository(username string, repo repoPattern, o cloneOptions) (string, error) {
+	tmp, err := os.MkdirTemp(".", "."+username+".reclone-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := vcs.Clone(repo.url(username), tmp, o); err != nil {
+		return "", err
+	}
+
+	// The old clone may be too broken to read, so this is best effort.
+	if old, err := filepath.Abs(username); err == nil {
+		vcs.FetchRefs(tmp, old, "refs/tags/deadline/*:refs/tags/deadline/*")
+	}
+	stashes, err := vcs.Stashes(username)
+	keep := err != nil || stashes > 0
+
+	aside := tmp + ".old"
+	if err := os.Rename(username, aside); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, username); err != nil {
+		os.Rename(aside, username)
+		return "", err
+	}
+	if !keep {
+		return "", os.RemoveAll(aside)
+	}
+	kept := filepath.Join(".scv", "recloned", username+"-"+time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(filepath.Dir(kept), 0o755); err != nil {
+		return aside, err
+	}
+	if err := os.Rename(aside, kept); err != nil {
+		return aside, err
+	}
+	return kept, nil
+}
+
+// planPull decides what pulling an inspected clone will do under a policy.
+func planPull(c cloneInspection, policy string) string {
+	switch {
+	case len(c.problems()) == 0 && c.Behind == 0:
+		return pullUpToDate
+	case len(c.problems()) == 0:
+		return pullPulled
+	case policy == pullStash && !c.URLMismatch && !c.detached() && c.Ahead == 0:
+		return pullStashed
+	case policy == pullReset:
+		return pullWasReset
+	case policy == pullReclone:
+		return pullRecloned
+	}
+	// Stashing can't fix a detached, diverged or repointed clone.
+	return pullSkipped
+}
+
+// pullWithPolicy inspects a clone and pulls it when that is safe, applying
+// the policy when it isn't, then applies the clone options. With dryRun it
+// only reports what it would do.
+func pullWithPolicy(username, policy string, repo repoPattern, o cloneOptions, dryRun bool) pullResult {
+	r := pullResult{Username: username}
+	if !isCloned(username) {
+		r.Action = pullNotCloned
+		return r
+	}
+	fail := func(err error) pullResult {
+		r.Action, r.Detail = pullFailed, err.Error()
+		return r
+	}
+
+	c, err := inspectClone(username, repo)
+	r.cloneInspection = c
+	switch {
+	case err == nil:
+		r.Action = planPull(c, policy)
+	// A clone too broken to inspect can still be re-cloned. One that only
+	// failed to fetch is left alone: GitHub or the network may be down.
+	case policy == pullReclone && errors.Is(err, errCloneUnreadable):
+		r.Action = pullRecloned
+	default:
+		return fail(err)
+	}
+	switch {
+	case err != nil:
+		r.Detail = err.Error()
+	case r.Action == pullPulled:
+		r.Detail = fmt.Sprintf("%d new commits", c.Behind)
+	default:
+		r.Detail = strings.Join(c.problems(), ", ")
+	}
+	if dryRun {
+		return r
+	}
+
+	switch r.Action {
+	case pullPulled:
+		if err := vcs.FastForward(username, c.Upstream); err != nil {
+			return fail(err)
+		}
+
+	case pullStashed:
+		message := "scv: before pull " + time.Now().Format(deadlineLayout)
+		if err := vcs.Stash(username, message); err != nil {
+			return fail(err)
+		}
+		if c.Behind > 0 {
+			if err := vcs.FastForward(username, c.Upstream); err != nil {
+				return fail(err)
+			}
+		}
+		r.Detail = fmt.Sprintf("%d changed files saved as %q, %d new commits", len(c.Dirty), message, c.Behind)
+
+	case pullWasReset:
+		if err := resetClone(username, repo); err != nil {
+			return fail(err)
+		}
+
+	case pullRecloned:
+		kept, err := recloneRepository(username, repo, o)
+		if err != nil {
+			return fail(err)
+		}
+		if kept != "" {
+			r.Detail += ", old clone kept in " + kept
+		}
+		return r
+
+	case pullSkipped:
+		return r
+	}
+	if err := vcs.Configure(username, o); err != nil {
+		return fail(fmt.Errorf("failed to apply clone options: %v", err))
+	}
+	return r
+}
+
+// classPullPolicy is the policy set for a class, skip by default.
+func classPullPolicy(className string) (string, error) {
+	var policy string
+	err := db.QueryRow("SELECT pull_policy FROM classes WHERE name = ? AND archived = 0", className).Scan(&policy)
+	if err != nil {
+		return "", fmt.Errorf("class not found: %s", className)
+	}
+	if policy == "" {
+		policy = pullSkip
+	}
+	return policy, nil
+}
+
+func validPullPolicy(policy string) error {
+	for _, p := range pullPolicies {
+		if p == policy {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown pull policy %q (want %s)", policy, strings.Join(pullPolicies, ", "))
+}
+
+func setPullPolicy(className, policy string) (string, error) {
+	if err := validPullPolicy(policy); err != nil {
+		return "", err
+	}
+	res, err := db.Exec("UPDATE classes SET pull_policy = ? WHERE name = ? AND archived = 0", policy, className)
+	if err != nil {
+		return "", fmt.Errorf("failed to set pull policy: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "", fmt.Errorf("class not found: %s", className)
+	}
+	return fmt.Sprintf("Pull policy for %s: %s\n", className, policy), nil
+}
+
+// pullClass pulls every clone in a class, with the class's clone options or
+// an assignment's. An empty policy uses the class's own.
+func pullClass(className, assignmentName, policy string, dryRun bool) ([]pullResult, string, error) {
+	if policy == "" {
+		var err error
+		if policy, err = classPullPolicy(className); err != nil {
+			return nil, "", err
+		}
+	} else if err := validPullPolicy(policy); err != nil {
+		return nil, "", err
+	}
+	o, err := classCloneOptions(className, assignmentName)
+	if err != nil {
+		return nil, "", err
+	}
+	repo, err := classRepoPattern(className)
+	if err != nil {
+		return nil, "", err
+	}
+	usernames, err := classStudents(className)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var results []pullResult
+	for _, username := range usernames {
+		results = append(results, pullWithPolicy(username, policy, repo, o, dryRun))
+	}
+	return results, policy, nil
+}
+
+func pullTable(results []pullResult) table {
+	t := table{header: []string{"username", "action", "branch", "ahead", "behind", "dirty_files", "detail"}}
+	for _, r := range results {
+		t.rows = append(t.rows, []string{
+			r.Username, r.Action, r.Branch, strconv.Itoa(r.Ahead), strconv.Itoa(r.Behind), strconv.Itoa(len(r.Dirty)), r.Detail,
+		})
+	}
+	return t
+}
+
+// pullText is the Pull Changes report as shown in the TUI.
+func pullText(className, policy string, results []pullResult, dryRun bool) string {
+	var sb strings.Builder
+	title := "Pull"
+	if dryRun {
+		title = "Pull preview"
+	}
+	sb.WriteString(fmt.Sprintf("%s for %s (policy: %s):\n", title, className, policy))
+	sb.WriteString("----------------------------------------\n")
+
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Action]++
+		line := r.Username + ": " + strings.ReplaceAll(r.Action, "_", " ")
+		if r.Detail != "" {
+			line += " (" + r.Detail + ")"
+		}
+		switch r.Action {
+		case pullPulled, pullUpToDate:
+			sb.WriteString(successStyle.Render(iconSuccess) + " " + line + "\n")
+		case pullFailed, pullNotCloned:
+			sb.WriteString(errorStyle.Render(iconError) + " " + line + "\n")
+		default:
+			sb.WriteString(warningStyle.Render(iconWarning) + " " + line + "\n")
+		}
+	}
+
+	var summary []string
+	for _, action := range []string{pullPulled, pullUpToDate, pullStashed, pullWasReset, pullRecloned, pullSkipped, pullNotCloned, pullFailed} {
+		if counts[action] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[action], strings.ReplaceAll(action, "_", " ")))
+		}
+	}
+	sb.WriteString("\n" + strings.Join(summary, ", ") + "\n")
+	return sb.String()
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultReportDays is the range used when --from is not given.
+const defaultReportDays = 28
+
+// repoStatus describes a student's local clone at report time.
+type repoStatus struct {
+	Cloned      bool
+	Head        string
+	LastCommit  time.Time
+	LastMessage string
+}
+
+func localRepoStatus(username string) repoStatus {
+	if !isCloned(username) {
+		return repoStatus{}
+	}
+	status := repoStatus{Cloned: true}
+	commits, err := vcs.Log(username, "", 1)
+	if err != nil || len(commits) == 0 {
+		return status
+	}
+	status.Head = fmt.Sprintf("%.7s", commits[0].SHA)
+	status.LastCommit, status.LastMessage = commits[0].Committed, commits[0].Subject
+	return status
+}
+
+// calendarDay is one cell of the push calendar.
+type calendarDay struct {
+	Date    time.Time
+	InRange bool
+	Commits int
+	Pushed  bool
+}
+
+// studentProgress is everything a progress report shows for one student.
+type studentProgress struct {
+	Username string
+	LastPush time.Time
+	PushDays int
+	Commits  int
+	Weeks    [][]calendarDay // Monday-Sunday rows covering the range
+	Repo     repoStatus
+	Notes    []note
+	Reviews  []reviewNote
+}
+
+type progressReport struct {
+	Class     string
+	From, To  time.Time
+	Generated time.Time
+	Students  []studentProgress
+}
+
+// storedPushCommits returns commits pushed per day (UTC, YYYY-MM-DD) in
+// [start, end]. Days with a push but no reported commits map to 0.
+func storedPushCommits(username string, start, end time.Time) (map[string]int, error) {
+	rows, err := db.Query(`
+		SELECT pushed_at, commits FROM activity_pushes
+		WHERE username = ? AND pushed_at >= ? AND pushed_at < ?`,
+		username, dayStart(start), dayStart(end).AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	commits := make(map[string]int)
+	for rows.Next() {
+		var pushedAt time.Time
+		var n int
+		if err := rows.Scan(&pushedAt, &n); err != nil {
+			return nil, err
+		}
+		commits[pushedAt.Format("2006-01-02")] += n
+	}
+	return commits, rows.Err()
+}
+
+func buildProgress(username string, from, to time.Time) (studentProgress, error) {
+	p := studentProgress{Username: username, Repo: localRepoStatus(username)}
+
+	commits, err := storedPushCommits(username, from, to)
+	if err != nil {
+		return p, err
+	}
+	p.LastPush, _ = storedLastPush(username)
+	p.Notes, err = studentNotes(username, from, to)
+	if err != nil {
+		return p, err
+	}
+	p.Reviews, err = reviewNotes(reviewFilter{Username: username, From: from, To: to})
+	if err != nil {
+		return p, err
+	}
+
+	first, last := dayStart(from), dayStart(to)
+	for week := weekOf(first); !week.After(last); week = week.AddDate(0, 0, 7) {
+		var row []calendarDay
+		for i := 0; i < 7; i++ {
+			d := week.AddDate(0, 0, i)
+			n, pushed := commits[d.Format("2006-01-02")]
+			day := calendarDay{Date: d, InRange: !d.Before(first) && !d.After(last)}
+			if day.InRange && pushed {
+				day.Pushed, day.Commits = true, n
+				p.PushDays++
+				p.Commits += n
+			}
+			row = append(row, day)
+		}
+		p.Weeks = append(p.Weeks, row)
+	}
+	return p, nil
+}
+
+// buildReport collects progress for one student, or the whole class when
+// username is empty.
+func buildReport(className, username string, from, to time.Time) (progressReport, error) {
+	report := progressReport{Class: className, From: from, To: to, Generated: time.Now()}
+
+	usernames, err := classStudents(className)
+	if err != nil {
+		return report, err
+	}
+	if username != "" {
+		found := false
+		for _, u := range usernames {
+			found = found || u == username
+		}
+		if !found {
+			return report, fmt.Errorf("student %s not found in class: %s", username, className)
+		}
+		usernames = []string{username}
+	}
+
+	for _, u := range usernames {
+		p, err := buildProgress(u, from, to)
+		if err != nil {
+			return report, err
+		}
+		report.Students = append(report.Students, p)
+	}
+	return report, nil
+}
+
+var reportFuncs = map[string]any{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Local().Format("Mon Jan 2, 2006")
+	},
+	"day": func(t time.Time) string {
+		return t.Format("01/02")
+	},
+	"ago": func(t time.Time) string {
+		return formatDuration(time.Since(t)) + " ago"
+	},
+}
+
+var markdownReport = template.Must(template.New("report").Funcs(reportFuncs).Parse(
+	`# Progress Report: {{.Class}}
+
+{{date .From}} – {{date .To}} · generated {{date .Generated}}
+{{range .Students}}
+## {{.Username}}
+
+- **Last push:** {{if .LastPush.IsZero}}none recorded{{else}}{{date .LastPush}} ({{ago .LastPush}}){{end}}
+- **Days with pushes:** {{.PushDays}}
+- **Commits pushed:** {{.Commits}}
+- **Repository:** {{if .Repo.Cloned}}cloned{{if .Repo.Head}}, last commit {{.Repo.Head}} on {{date .Repo.LastCommit}}: "{{.Repo.LastMessage}}"{{end}}{{else}}not cloned{{end}}
+
+### Push calendar
+
+| Mon | Tue | Wed | Thu | Fri | Sat | Sun |
+| --- | --- | --- | --- | --- | --- | --- |
+{{range .Weeks}}|{{range .}} {{if .InRange}}{{day .Date}}{{if .Pushed}} ✔ {{.Commits}}{{end}}{{end}} |{{end}}
+{{end}}
+### Teacher notes
+{{range .Notes}}
+- {{date .CreatedAt}}: {{.Body}}{{else}}
+_No notes for this period._{{end}}
+{{if .Reviews}}
+### Code review notes
+{{range .Reviews}}
+- **{{.Path}}:{{.Lines}}** ({{.ShortSHA}}{{if .Assignment}}, {{.Assignment}}{{end}}): {{.Body}}
+{{.Quoted}}{{end}}{{end}}
+{{end}}`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Progress Report: {{.Class}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; max-width: 60em; }
+h1, h2 { color: #b0306f; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: center; min-width: 4em; }
+td.pushed { background: #d4f7d4; font-weight: bold; }
+td.out { background: #f4f4f4; }
+pre { background: #f4f4f4; padding: 0.5em; overflow-x: auto; }
+section { page-break-after: always; }
+</style>
+</head>
+<body>
+<h1>Progress Report: {{.Class}}</h1>
+<p>{{date .From}} – {{date .To}} · generated {{date .Generated}}</p>
+{{range .Students}}
+<section>
+<h2>{{.Username}}</h2>
+<ul>
+<li><strong>Last push:</strong> {{if .LastPush.IsZero}}none recorded{{else}}{{date .LastPush}} ({{ago .LastPush}}){{end}}</li>
+<li><strong>Days with pushes:</strong> {{.PushDays}}</li>
+<li><strong>Commits pushed:</strong> {{.Commits}}</li>
+<li><strong>Repository:</strong> {{if .Repo.Cloned}}cloned{{if .Repo.Head}}, last commit {{.Repo.Head}} on {{date .Repo.LastCommit}}: &ldquo;{{.Repo.LastMessage}}&rdquo;{{end}}{{else}}not cloned{{end}}</li>
+</ul>
+<h3>Push calendar</h3>
+<table>
+<tr><th>Mon</th><th>Tue</th><th>Wed</th><th>Thu</th><th>Fri</th><th>Sat</th><th>Sun</th></tr>
+{{range .Weeks}}<tr>{{range .}}{{if not .InRange}}<td class="out"></td>{{else if .Pushed}}<td class="pushed">{{day .Date}}<br>✔ {{.Commits}}</td>{{else}}<td>{{day .Date}}</td>{{end}}{{end}}</tr>
+{{end}}</table>
+<h3>Teacher notes</h3>
+{{if .Notes}}<ul>{{range .Notes}}<li>{{date .CreatedAt}}: {{.Body}}</li>{{end}}</ul>{{else}}<p><em>No notes for this period.</em></p>{{end}}
+{{if .Reviews}}<h3>Code review notes</h3>
+<ul>{{range .Reviews}}<li><strong>{{.Path}}:{{.Lines}}</strong> ({{.ShortSHA}}{{if .Assignment}}, {{.Assignment}}{{end}}): {{.Body}}<pre>{{.Snippet}}</pre></li>{{end}}</ul>{{end}}
+</section>
+{{end}}
+</body>
+</html>
+`))
+
+// writeReport renders a report as markdown or html into dir and returns the
+// file path. Class reports are named after the class, student reports
+// <class>-<username>, with anything but letters, digits, dots, dashes and
+// underscores replaced so the name can't leave dir.
+func writeReport(report progressReport, username, format, dir string) (string, error) {
+	var buf bytes.Buffer
+	var ext string
+	switch format {
+	case formatMarkdown:
+		ext = ".md"
+		if err := markdownReport.Execute(&buf, report); err != nil {
+			return "", err
+		}
+	case formatHTML:
+		ext = ".html"
+		if err := htmlReport.Execute(&buf, report); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unknown report format %q (want markdown or html)", format)
+	}
+
+	name := report.Class
+	if username != "" {
+		name += "-" + username
+	}
+	name = strings.Trim(unsafeRefChars.ReplaceAllString(name, "-"), "-.")
+	if name == "" {
+		name = "report"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name+ext)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// generateReport builds and saves a progress report, returning a summary.
+func generateReport(className, username string, from, to time.Time, format, dir string) (string, error) {
+	report, err := buildReport(className, username, from, to)
+	if err != nil {
+		return "", err
+	}
+	path, err := writeReport(report, username, format, dir)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Saved progress report for %d students to: %s\n", len(report.Students), path), nil
+}
@@ -0,0 +1,10 @@
+package main
+
+import "os"
+
+// isCloned reports whether a student's repository has been cloned into the
+// workspace. Clones live in a directory named after the student.
+func isCloned(username string) bool {
+	_, err := os.Stat(username)
+	return err == nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSnippetLines caps how much code is saved with a review note.
+const maxSnippetLines = 20
+
+// reviewNote is a note on a range of lines in a student's file, pinned to
+// the commit that was checked out when it was written. The lines are saved
+// with the note so it still makes sense after the file changes.
+type reviewNote struct {
+	ID         int       `json:"id"`
+	Username   string    `json:"username"`
+	Assignment string    `json:"assignment,omitempty"`
+	SHA        string    `json:"sha"`
+	Path       string    `json:"path"`
+	LineStart  int       `json:"line_start"`
+	LineEnd    int       `json:"line_end"`
+	Body       string    `json:"body"`
+	Snippet    string    `json:"snippet"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Lines is the range as shown to the teacher, e.g. "12" or "12-18".
+func (n reviewNote) Lines() string {
+	if n.LineEnd > n.LineStart {
+		return fmt.Sprintf("%d-%d", n.LineStart, n.LineEnd)
+	}
+	return strconv.Itoa(n.LineStart)
+}
+
+// ShortSHA is the abbreviated commit, for the report templates.
+func (n reviewNote) ShortSHA() string {
+	return fmt.Sprintf("%.7s", n.SHA)
+}
+
+// Quoted is the snippet as an indented Markdown code block.
+func (n reviewNote) Quoted() string {
+	var sb strings.Builder
+	sb.WriteString("\n")
+	for _, line := range strings.Split(n.Snippet, "\n") {
+		sb.WriteString("      " + line + "\n")
+	}
+	return sb.String()
+}
+
+// location is path:lines@sha.
+func (n reviewNote) location() string {
+	return fmt.Sprintf("%s:%s @ %.7s", n.Path, n.Lines(), n.SHA)
+}
+
+// permalink points at the noted lines on GitHub as they were when the note
+// was written. The path is escaped so it can go in a Markdown link.
+func (n reviewNote) permalink(repo repoPattern) string {
+	segments := strings.Split(n.Path, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	link := fmt.Sprintf("%s/blob/%s/%s#L%d", repo.url(n.Username), n.SHA, strings.Join(segments, "/"), n.LineStart)
+	if n.LineEnd > n.LineStart {
+		link += fmt.Sprintf("-L%d", n.LineEnd)
+	}
+	return link
+}
+
+// parseLineRange reads "12" or "12-18".
+func parseLineRange(s string) (start, end int, err error) {
+	first, last, isRange := strings.Cut(strings.TrimSpace(s), "-")
+	start, err = strconv.Atoi(strings.TrimSpace(first))
+	end = start
+	if err == nil && isRange {
+		end, err = strconv.Atoi(strings.TrimSpace(last))
+	}
+	if err != nil || start < 1 || end < start {
+		return 0, 0, fmt.Errorf("invalid line range %q (want e.g. 12 or 12-18)", s)
+	}
+	return start, end, nil
+}
+
+// addReviewNote attaches a note to lines of a file in a student's clone.
+// a may be nil for notes that don't belong to an assignment.
+func addReviewNote(username string, a *assignment, path, lineRange, body string) (reviewNote, error) {
+	n := reviewNote{Username: username, Path: filepath.ToSlash(filepath.Clean(path)), Body: strings.TrimSpace(body), CreatedAt: time.Now()}
+	if n.Body == "" {
+		return n, fmt.Errorf("note is empty")
+	}
+	if !isCloned(username) {
+		return n, fmt.Errorf("%s is not cloned", username)
+	}
+	if !filepath.IsLocal(n.Path) {
+		return n, fmt.Errorf("path %s is outside the repository", path)
+	}
+	var err error
+	if n.LineStart, n.LineEnd, err = parseLineRange(lineRange); err != nil {
+		return n, err
+	}
+	data, err := os.ReadFile(filepath.Join(username, n.Path))
+	if err != nil {
+		return n, err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if n.LineEnd > len(lines) {
+		return n, fmt.Errorf("%s ends at line %d", n.Path, len(lines))
+	}
+	n.Snippet = strings.Join(lines[n.LineStart-1:min(n.LineEnd, n.LineStart-1+maxSnippetLines)], "\n")
+	if n.SHA, err = vcs.Head(username); err != nil {
+		return n, err
+	}
+
+	var assignmentID any
+	if a != nil {
+		assignmentID, n.Assignment = a.ID, a.Name
+	}
+	res, err := db.Exec(`
+		INSERT INTO review_notes (username, assignment_id, sha, path, line_start, line_end, body, snippet, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		username, assignmentID, n.SHA, n.Path, n.LineStart, n.LineEnd, n.Body, n.Snippet, n.CreatedAt.UTC())
+	if err != nil {
+		return n, fmt.Errorf("failed to save note: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	n.ID = int(id)
+	return n, nil
+}
+
+func deleteReviewNote(id int) (string, error) {
+	res, err := db.Exec("DELETE FROM review_notes WHERE id = ?", id)
+	if err != nil {
+		return "", fmt.Errorf("failed to delete note: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "", fmt.Errorf("review note %d not found", id)
+	}
+	return fmt.Sprintf("Deleted review note %d\n", id), nil
+}
+
+// reviewFilter selects review notes; zero fields match everything.
+type reviewFilter struct {
+	Username     string
+	AssignmentID int
+	Path         string
+	From, To     time.Time
+}
+
+// reviewNotes returns the notes matching f, by file and line.
+func reviewNotes(f reviewFilter) ([]reviewNote, error) {
+	query := `
+		SELECT r.id, r.username, COALESCE(a.name, ''), r.sha, r.path, r.line_start, r.line_end, r.body, r.snippet, r.created_at
+		FROM review_notes r
+		LEFT JOIN assignments a ON r.assignment_id = a.id
+		WHERE r.username = ?`
+	args := []any{f.Username}
+	if f.AssignmentID != 0 {
+		query += " AND r.assignment_id = ?"
+		args = append(args, f.AssignmentID)
+	}
+	if f.Path != "" {
+		query += " AND r.path = ?"
+		args = append(args, f.Path)
+	}
+	if !f.From.IsZero() {
+		query += " AND r.created_at >= ?"
+		args = append(args, dayStart(f.From))
+	}
+	if !f.To.IsZero() {
+		query += " AND r.created_at < ?"
+		args = append(args, dayStart(f.To).AddDate(0, 0, 1))
+	}
+	query += " ORDER BY r.path, r.line_start, r.created_at"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []reviewNote
+	for rows.Next() {
+		var n reviewNote
+		err := rows.Scan(&n.ID, &n.Username, &n.Assignment, &n.SHA, &n.Path, &n.LineStart, &n.LineEnd, &n.Body, &n.Snippet, &n.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+// changedSince reports whether the noted file differs in the clone's HEAD
+// from the commit the note was written against.
+func (n reviewNote) changedSince() bool {
+	changed, err := vcs.Changed(n.Username, n.Path, n.SHA, "HEAD")
+	return changed || err != nil
+}
+
+// listReviewNotes is the per-student listing; with an assignment, only its
+// notes.
+func listReviewNotes(className, username, assignmentName string) ([]reviewNote, error) {
+	if err := requireStudent(className, username); err != nil {
+		return nil, err
+	}
+	f := reviewFilter{Username: username}
+	if assignmentName != "" {
+		a, err := findAssignment(className, assignmentName)
+		if err != nil {
+			return nil, err
+		}
+		f.AssignmentID = a.ID
+	}
+	return reviewNotes(f)
+}
+
+func reviewNotesText(username string, notes []reviewNote) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Review notes for %s:\n", username))
+	for _, n := range notes {
+		sb.WriteString(fmt.Sprintf("\n#%d %s", n.ID, n.location()))
+		if n.Assignment != "" {
+			sb.WriteString(" [" + n.Assignment + "]")
+		}
+		if isCloned(n.Username) && n.changedSince() {
+			sb.WriteString(" (file changed since)")
+		}
+		sb.WriteString(fmt.Sprintf("\n%s\n", n.Body))
+		for _, line := range strings.Split(n.Snippet, "\n") {
+			sb.WriteString("    | " + line + "\n")
+		}
+	}
+	if len(notes) == 0 {
+		sb.WriteString("No review notes.\n")
+	}
+	return sb.String()
+}
+
+func reviewTable(notes []reviewNote) table {
+	t := table{header: []string{"id", "username", "assignment", "path", "lines", "sha", "note", "created_at"}}
+	for _, n := range notes {
+		t.rows = append(t.rows, []string{
+			strconv.Itoa(n.ID), n.Username, n.Assignment, n.Path, n.Lines(), n.SHA, n.Body, n.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return t
+}
+
+// findReviewAssignment resolves the optional --assignment of review
+// commands.
+func findReviewAssignment(className, assignmentName string) (*assignment, error) {
+	if assignmentName == "" {
+		return nil, nil
+	}
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
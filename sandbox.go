@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// sandboxLimits caps what a check may use. CPU, memory, file size and
+// process limits are only enforced on Linux.
+type sandboxLimits struct {
+	Timeout    time.Duration // wall clock
+	CPU        time.Duration // CPU time per process
+	MemoryMB   int64         // address space per process
+	FileSizeMB int64         // largest file a check may write
+	Processes  int           // processes for the teacher's user; see runSandboxExec
+	Network    bool          // allow network access
+}
+
+var defaultSandboxLimits = sandboxLimits{
+	Timeout:    defaultCheckTimeout,
+	CPU:        time.Minute,
+	MemoryMB:   1024,
+	FileSizeMB: 100,
+	Processes:  1024,
+}
+
+// Limits a check can run into, as reported in checkResult.Limit.
+const (
+	limitTime     = "time"
+	limitCPU      = "CPU"
+	limitMemory   = "memory"
+	limitFileSize = "file size"
+)
+
+// sandboxExecArg makes the binary act as the sandbox's exec wrapper
+// instead of starting normally; see runSandboxExec.
+const sandboxExecArg = "__sandbox-exec"
+
+// outOfMemory are messages runtimes print when an allocation fails, which
+// is how hitting the memory limit shows up.
+var outOfMemory = []string{
+	"out of memory", "cannot allocate memory", "memoryerror", "bad_alloc", "allocation failed",
+}
+
+// copyRepo copies a clone into dst without its .git directory, so a check
+// can neither change the teacher's copy nor read its history. Symlinks are
+// kept only if they point inside the repository.
+func copyRepo(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case d.IsDir():
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0o755)
+
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			resolved := filepath.Join(filepath.Dir(rel), link)
+			if filepath.IsAbs(link) || !filepath.IsLocal(resolved) {
+				return nil
+			}
+			return os.Symlink(link, target)
+
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// sandboxEnv is the whole environment a check sees: nothing from the
+// teacher's session (tokens, SSH agent, cloud credentials) beyond PATH.
+func sandboxEnv(home, username string) []string {
+	env := []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + home,
+		"TMPDIR=" + home,
+		"LANG=C.UTF-8",
+		"TERM=dumb",
+		"CI=true",
+		"SCV_STUDENT=" + username,
+	}
+	if runtime.GOOS == "windows" {
+		for _, name := range []string{"SystemRoot", "ComSpec", "PATHEXT", "TEMP", "TMP"} {
+			env = append(env, name+"="+os.Getenv(name))
+		}
+	}
+	return env
+}
+
+// sandboxRun is a prepared check: a scratch copy of the repository and a
+// command that runs in it.
+type sandboxRun struct {
+	username string
+	root     string // removed by cleanup
+	dir      string // the repository copy
+	home     string
+	notes    []string // what isolation is (or isn't) in effect
+}
+
+func newSandboxRun(username string) (*sandboxRun, error) {
+	root, err := os.MkdirTemp("", "scv-check-")
+	if err != nil {
+		return nil, err
+	}
+	run := &sandboxRun{username: username, root: root, dir: filepath.Join(root, "repo"), home: filepath.Join(root, "home")}
+	if err := os.Mkdir(run.home, 0o700); err != nil {
+		run.cleanup()
+		return nil, err
+	}
+	if err := copyRepo(username, run.dir); err != nil {
+		run.cleanup()
+		return nil, fmt.Errorf("failed to copy repository: %v", err)
+	}
+	return run, nil
+}
+
+func (r *sandboxRun) cleanup() {
+	os.RemoveAll(r.root)
+}
+
+// limitHit works out which limit stopped a check, if any.
+func limitHit(ctx context.Context, err error, output string) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return limitTime
+	}
+	if err == nil {
+		return ""
+	}
+	if limit := signalLimit(err); limit != "" {
+		return limit
+	}
+	lower := strings.ToLower(output)
+	for _, msg := range outOfMemory {
+		if strings.Contains(lower, msg) {
+			return limitMemory
+		}
+	}
+	return ""
+}
+
+// describe summarises the sandbox for the Run Checks header.
+func (l sandboxLimits) describe() string {
+	parts := []string{"scratch copy", "clean environment", fmt.Sprintf("%s timeout", l.Timeout)}
+	if sandboxEnforcesLimits {
+		parts = append(parts, fmt.Sprintf("%s CPU", l.CPU), fmt.Sprintf("%d MB memory", l.MemoryMB),
+			fmt.Sprintf("%d MB files", l.FileSizeMB), fmt.Sprintf("%d processes", l.Processes))
+	}
+	if l.Network {
+		parts = append(parts, "network allowed")
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,190 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const sandboxEnforcesLimits = true
+
+// start runs the check through the sandbox exec wrapper in its own process
+// group. Without network access it also gets new user and network
+// namespaces; if the kernel doesn't allow that, the check runs with the
+// network and a note says so.
+func (r *sandboxRun) start(ctx context.Context, command string, limits sandboxLimits, out io.Writer) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	build := func(isolate bool) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, exe, sandboxExecArg,
+			strconv.Itoa(int(limits.CPU.Seconds())),
+			strconv.FormatInt(limits.MemoryMB, 10),
+			strconv.FormatInt(limits.FileSizeMB, 10),
+			strconv.Itoa(limits.Processes),
+			strconv.FormatBool(isolate),
+			"--", "sh", "-c", command)
+		cmd.Dir, cmd.Env = r.dir, sandboxEnv(r.home, r.username)
+		cmd.Stdout, cmd.Stderr = out, out
+		cmd.WaitDelay = 5 * time.Second
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if isolate {
+			// Map the teacher's user to root inside the namespace, which
+			// only grants control over the empty network namespace.
+			cmd.SysProcAttr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+			cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+			cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+		}
+		cmd.Cancel = func() error {
+			return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+		return cmd
+	}
+
+	if !limits.Network {
+		cmd := build(true)
+		err := cmd.Start()
+		if err == nil {
+			r.notes = append(r.notes, "no network")
+			return cmd, nil
+		}
+		if !errors.Is(err, syscall.EPERM) && !errors.Is(err, syscall.EACCES) &&
+			!errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOSPC) {
+			return nil, err
+		}
+		r.notes = append(r.notes, "network not isolated (user namespaces are disabled)")
+	}
+
+	cmd := build(false)
+	return cmd, cmd.Start()
+}
+
+// killProcessGroup stops anything the check left running in the background.
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// signalLimit recognises the signals the kernel sends when a resource
+// limit is exceeded, whether the shell itself or a command it ran died.
+func signalLimit(err error) string {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return ""
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return ""
+	}
+
+	var sig syscall.Signal
+	switch {
+	case status.Signaled():
+		sig = status.Signal()
+	case status.ExitStatus() > 128:
+		sig = syscall.Signal(status.ExitStatus() - 128)
+	}
+	switch sig {
+	case syscall.SIGXCPU:
+		return limitCPU
+	case syscall.SIGXFSZ:
+		return limitFileSize
+	}
+	return ""
+}
+
+// runSandboxExec is the sandbox's exec wrapper. It applies the resource
+// limits, brings up loopback networking if it is in a new network namespace
+// and replaces itself with the check command. Arguments:
+//
+//	<cpu-seconds> <memory-mb> <file-size-mb> <processes> <isolated> -- <command...>
+func runSandboxExec(args []string) {
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(126)
+	}
+	if len(args) < 7 || args[5] != "--" {
+		fail(fmt.Errorf("usage: %s <cpu> <memory> <fsize> <nproc> <isolated> -- <command...>", sandboxExecArg))
+	}
+
+	var values [4]uint64
+	for i := range values {
+		v, err := strconv.ParseUint(args[i], 10, 64)
+		if err != nil {
+			fail(err)
+		}
+		values[i] = v
+	}
+	cpu, memory, fileSize, processes := values[0], values[1]<<20, values[2]<<20, values[3]
+
+	limits := []struct {
+		resource  int
+		soft, max uint64
+	}{
+		// The hard CPU limit leaves time for SIGXCPU to be reported first.
+		{unix.RLIMIT_CPU, cpu, cpu + 5},
+		// Address space rather than the data segment, which doesn't
+		// cover memory runtimes map directly.
+		{unix.RLIMIT_AS, memory, memory},
+		{unix.RLIMIT_FSIZE, fileSize, fileSize},
+		// Linux counts processes per user, so this includes everything
+		// else the teacher is running. In a new user namespace (Linux 5.14
+		// and later) only the check's own processes count.
+		{unix.RLIMIT_NPROC, processes, processes},
+		{unix.RLIMIT_CORE, 0, 0},
+	}
+	for _, l := range limits {
+		// Unprivileged processes can't raise a hard limit, only lower it.
+		var current unix.Rlimit
+		if err := unix.Getrlimit(l.resource, &current); err == nil {
+			l.soft, l.max = min(l.soft, current.Max), min(l.max, current.Max)
+		}
+		if err := unix.Setrlimit(l.resource, &unix.Rlimit{Cur: l.soft, Max: l.max}); err != nil {
+			fail(fmt.Errorf("setrlimit: %v", err))
+		}
+	}
+
+	if args[4] == "true" {
+		// Checks that start a local server still need localhost.
+		if err := loopbackUp(); err != nil {
+			fail(fmt.Errorf("loopback: %v", err))
+		}
+	}
+
+	path, err := exec.LookPath(args[6])
+	if err != nil {
+		fail(err)
+	}
+	fail(syscall.Exec(path, args[6:], os.Environ()))
+}
+
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
@@ -0,0 +1,34 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const sandboxEnforcesLimits = false
+
+// start runs the check through the shell. Outside Linux only the scratch
+// copy, clean environment, timeout and process tree cleanup apply.
+func (r *sandboxRun) start(ctx context.Context, command string, limits sandboxLimits, out io.Writer) (*exec.Cmd, error) {
+	r.notes = append(r.notes, "resource limits and network isolation need Linux")
+	cmd := shellCommand(ctx, command)
+	cmd.Dir, cmd.Env = r.dir, sandboxEnv(r.home, r.username)
+	cmd.Stdout, cmd.Stderr = out, out
+	cmd.WaitDelay = 5 * time.Second
+	return cmd, startProcessGroup(cmd)
+}
+
+func signalLimit(err error) string {
+	return ""
+}
+
+func runSandboxExec(args []string) {
+	fmt.Fprintln(os.Stderr, "sandbox: only available on Linux")
+	os.Exit(126)
+}
@@ -0,0 +1,69 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// checkJobs holds the job object each running check was put in. Windows
+// has no process groups; terminating the job stops the whole tree.
+var (
+	checkJobsMu sync.Mutex
+	checkJobs   = map[*exec.Cmd]windows.Handle{}
+)
+
+// startProcessGroup starts cmd and puts it in a job object that its child
+// processes inherit. If the job can't be set up, a timeout only stops the
+// shell.
+func startProcessGroup(cmd *exec.Cmd) error {
+	cmd.Cancel = func() error {
+		killProcessGroup(cmd)
+		return cmd.Process.Kill()
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil
+	}
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	_, err = windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+	if err == nil {
+		var process windows.Handle
+		process, err = windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+		if err == nil {
+			err = windows.AssignProcessToJobObject(job, process)
+			windows.CloseHandle(process)
+		}
+	}
+	if err != nil {
+		windows.CloseHandle(job)
+		return nil
+	}
+
+	checkJobsMu.Lock()
+	checkJobs[cmd] = job
+	checkJobsMu.Unlock()
+	return nil
+}
+
+// killProcessGroup stops anything the check left running in the background.
+func killProcessGroup(cmd *exec.Cmd) {
+	checkJobsMu.Lock()
+	job, ok := checkJobs[cmd]
+	delete(checkJobs, cmd)
+	checkJobsMu.Unlock()
+	if ok {
+		windows.TerminateJobObject(job, 1)
+		windows.CloseHandle(job)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+)
+
+var dashboardFuncs = template.FuncMap{
+	"ago": func(t time.Time) string {
+		return formatDuration(time.Since(t))
+	},
+	"dateKey": func(t time.Time) string {
+		return t.Format("2006-01-02")
+	},
+	"dayHeader": func(t time.Time) string {
+		return t.Format("Mon 01/02")
+	},
+	"icon": func(status string) string {
+		switch status {
+		case statusActive:
+			return iconSuccess
+		case statusRecent:
+			return iconWarning
+		default:
+			return iconError
+		}
+	},
+}
+
+var dashboardTemplate = template.Must(template.New("layout").Funcs(dashboardFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>Student Code Viewer{{with .Class}} - {{.}}{{end}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; background: #1e1e2e; color: #eee; }
+h1, h2 { color: #FF75B5; }
+a { color: #8ab4f8; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #555; padding: 0.4em 0.8em; text-align: center; }
+th { color: #FFFF00; }
+.active { color: #00FF00; font-weight: bold; }
+.recent { color: #FFFF00; font-weight: bold; }
+.inactive, .error { color: #FF0000; font-weight: bold; }
+.legend { color: #aaa; }
+</style>
+</head>
+<body>
+{{if .Class}}
+<p><a href="/">&larr; All classes</a></p>
+<h1>{{.Class}}</h1>
+
+<h2>Activity</h2>
+<table>
+<tr><th></th><th>Username</th><th>Last push</th></tr>
+{{range .Students}}
+<tr class="{{.Status}}">
+<td>{{icon .Status}}</td>
+<td>{{.Username}}</td>
+<td>{{if .Error}}{{.Error}}{{else}}{{ago .LastPush}} ago{{end}}</td>
+</tr>
+{{end}}
+</table>
+
+<h2>Week History</h2>
+<table>
+<tr><th>Username</th>{{range .Days}}<th>{{dayHeader .}}</th>{{end}}</tr>
+{{range $s := .Students}}
+<tr>
+<td>{{$s.Username}}</td>
+{{range $.Days}}{{if index $s.PushDays (dateKey .)}}<td class="active">✓</td>{{else}}<td class="error">✖</td>{{end}}{{end}}
+</tr>
+{{end}}
+</table>
+
+<p class="legend">{{icon "active"}} pushed within 24 hours &middot; {{icon "recent"}} within 72 hours &middot; {{icon "inactive"}} no push in over 72 hours</p>
+{{else}}
+<h1>Student Code Viewer</h1>
+<h2>Classes</h2>
+<ul>
+{{range .Classes}}<li><a href="/class/{{.}}">{{.}}</a></li>{{else}}<li>No classes yet.</li>{{end}}
+</ul>
+{{end}}
+<p class="legend">Updated {{.Now.Format "Mon Jan 2 15:04:05"}}, refreshing every {{.Refresh}}s.</p>
+</body>
+</html>
+`))
+
+// dashboardPage is the data rendered by dashboardTemplate. Class is empty
+// on the class list page.
+type dashboardPage struct {
+	Refresh  int
+	Now      time.Time
+	Classes  []string
+	Class    string
+	Students []studentActivity
+	Days     []time.Time
+}
+
+// dashboard serves pages built only from the database; run `scv daemon`
+// alongside it to keep the data fresh.
+type dashboard struct {
+	refresh time.Duration
+}
+
+func (d dashboard) page() dashboardPage {
+	return dashboardPage{Refresh: int(d.refresh.Seconds()), Now: time.Now()}
+}
+
+func (d dashboard) render(w http.ResponseWriter, page dashboardPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, page); err != nil {
+		log.Printf("failed to render dashboard: %v", err)
+	}
+}
+
+func (d dashboard) handleIndex(w http.ResponseWriter, r *http.Request) {
+	classes, err := activeClasses()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page := d.page()
+	page.Classes = classes
+	d.render(w, page)
+}
+
+func (d dashboard) handleClass(w http.ResponseWriter, r *http.Request) {
+	className := r.PathValue("name")
+
+	if !classExists(className) {
+		http.NotFound(w, r)
+		return
+	}
+
+	students, err := classActivity(className, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page := d.page()
+	page.Class = className
+	page.Students = students
+	page.Days = gridDays()
+	d.render(w, page)
+}
+
+// dashboardMux routes the dashboard pages.
+func dashboardMux(refresh time.Duration) *http.ServeMux {
+	d := dashboard{refresh: refresh}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", d.handleIndex)
+	mux.HandleFunc("GET /class/{name}", d.handleClass)
+	return mux
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Winnowing parameters (Schleimer et al.): every match of at least
+// simWindow+simKGram-1 tokens is guaranteed to be detected, and matches
+// shorter than simKGram tokens are ignored.
+const (
+	simKGram  = 12
+	simWindow = 8
+
+	// defaultSimilarityThreshold is the share of a submission's fingerprints
+	// that must appear in another submission for the pair to be reported.
+	defaultSimilarityThreshold = 0.5
+
+	// maxSimilarityBytes skips generated or vendored files that would
+	// dominate the comparison.
+	maxSimilarityBytes = 512 * 1024
+)
+
+// similarityExts are the source files compared between students.
+var similarityExts = map[string]bool{
+	".html": true, ".htm": true, ".css": true, ".js": true, ".py": true,
+}
+
+// similaritySkipDirs are never walked.
+var similaritySkipDirs = map[string]bool{
+	".git": true, "node_modules": true, "__pycache__": true, "venv": true, ".venv": true,
+}
+
+type token struct {
+	text string
+	line int
+}
+
+// tokenize splits source into lowercase words and single punctuation
+// characters, so layout and whitespace changes don't hide a copy.
+func tokenize(src string) []token {
+	var tokens []token
+	line := 1
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\n':
+			line++
+			i++
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{strings.ToLower(string(runes[start:i])), line})
+		default:
+			tokens = append(tokens, token{string(r), line})
+			i++
+		}
+	}
+	return tokens
+}
+
+// location is the line span of a fingerprinted k-gram in a file.
+type location struct {
+	File       string
+	Start, End int
+}
+
+// fingerprints maps each selected k-gram hash to where it occurs.
+type fingerprints map[uint64][]location
+
+// winnow adds the fingerprints of one file: the minimum hash of every
+// window of simWindow consecutive k-gram hashes.
+func (fp fingerprints) winnow(file, src string) {
+	tokens := tokenize(src)
+	if len(tokens) < simKGram {
+		return
+	}
+
+	hashes := make([]uint64, len(tokens)-simKGram+1)
+	for i := range hashes {
+		h := fnv.New64a()
+		for _, t := range tokens[i : i+simKGram] {
+			h.Write([]byte(t.text))
+			h.Write([]byte{0})
+		}
+		hashes[i] = h.Sum64()
+	}
+
+	last := -1
+	record := func(i int) {
+		if i == last {
+			return
+		}
+		last = i
+		fp[hashes[i]] = append(fp[hashes[i]], location{file, tokens[i].line, tokens[i+simKGram-1].line})
+	}
+
+	if len(hashes) < simWindow {
+		lowest := 0
+		for i := range hashes {
+			if hashes[i] <= hashes[lowest] {
+				lowest = i
+			}
+		}
+		record(lowest)
+		return
+	}
+	for start := 0; start+simWindow <= len(hashes); start++ {
+		// Rightmost minimum, so a window sliding over the same minimum
+		// doesn't record it twice.
+		lowest := start
+		for i := start; i < start+simWindow; i++ {
+			if hashes[i] <= hashes[lowest] {
+				lowest = i
+			}
+		}
+		record(lowest)
+	}
+}
+
+func similarityFile(path string, size int64) bool {
+	if size > maxSimilarityBytes || strings.Contains(filepath.Base(path), ".min.") {
+		return false
+	}
+	return similarityExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// repoFingerprints fingerprints the source files in a student's clone.
+func repoFingerprints(username string) (fingerprints, error) {
+	fp := make(fingerprints)
+	err := filepath.WalkDir(username, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if similaritySkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !similarityFile(path, info.Size()) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(data, 0) >= 0 {
+			return nil
+		}
+		rel, _ := filepath.Rel(username, path)
+		fp.winnow(filepath.ToSlash(rel), string(data))
+		return nil
+	})
+	return fp, err
+}
+
+// starterFingerprints fingerprints the source files of an assignment's
+// starter commit, read straight from the cached bare clone.
+func starterFingerprints(a assignment) (fingerprints, error) {
+	sha, err := syncStarter(a)
+	if err != nil {
+		return nil, err
+	}
+	dir := starterDir(a)
+	out, err := git("-C", dir, "ls-tree", "-r", "--long", sha)
+	if err != nil {
+		return nil, err
+	}
+
+	fp := make(fingerprints)
+	for _, line := range strings.Split(out, "\n") {
+		// <mode> <type> <object> <size>\t<path>
+		meta, path, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 4 || fields[1] != "blob" {
+			continue
+		}
+		var size int64
+		fmt.Sscan(fields[3], &size)
+		if !similarityFile(path, size) {
+			continue
+		}
+		src, err := git("-C", dir, "cat-file", "blob", fields[2])
+		if err != nil {
+			return nil, err
+		}
+		fp.winnow(path, src)
+	}
+	return fp, nil
+}
+
+// region is a stretch of matching code between two students' files.
+type region struct {
+	FileA        string
+	StartA, EndA int
+	FileB        string
+	StartB, EndB int
+	Fingerprints int
+}
+
+// similarPair is two submissions sharing more fingerprints than the
+// threshold allows. ScoreA is the share of A's fingerprints found in B.
+type similarPair struct {
+	A, B           string
+	ScoreA, ScoreB float64
+	Shared         int
+	Regions        []region
+}
+
+func (p similarPair) score() float64 {
+	return max(p.ScoreA, p.ScoreB)
+}
+
+// matchRegions merges the shared fingerprints of a pair into line ranges,
+// largest first.
+func matchRegions(a, b fingerprints, shared []uint64) []region {
+	type filePair struct{ a, b string }
+	spans := make(map[filePair][]region)
+	for _, h := range shared {
+		la, lb := a[h][0], b[h][0]
+		key := filePair{la.File, lb.File}
+		spans[key] = append(spans[key], region{la.File, la.Start, la.End, lb.File, lb.Start, lb.End, 1})
+	}
+
+	var regions []region
+	for _, rs := range spans {
+		sort.Slice(rs, func(i, j int) bool { return rs[i].StartA < rs[j].StartA })
+		current := rs[0]
+		for _, r := range rs[1:] {
+			// Fingerprints a few lines apart belong to the same copied block.
+			if r.StartA <= current.EndA+2 {
+				current.EndA = max(current.EndA, r.EndA)
+				current.StartB = min(current.StartB, r.StartB)
+				current.EndB = max(current.EndB, r.EndB)
+				current.Fingerprints++
+				continue
+			}
+			regions = append(regions, current)
+			current = r
+		}
+		regions = append(regions, current)
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i].Fingerprints > regions[j].Fingerprints })
+	return regions
+}
+
+// classSimilarity compares every pair of cloned submissions in a class.
+// Fingerprints from the assignment's starter (when assignmentName names one
+// that has a starter) are treated as boilerplate and ignored. Without a
+// starter, those shared by more than half of a class of four or more
+// students are ignored instead; with one, code most of the class shares
+// beyond it is worth seeing.
+func classSimilarity(className, assignmentName string, threshold float64) ([]similarPair, []string, error) {
+	usernames, err := classStudents(className)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ignore fingerprints
+	if assignmentName != "" {
+		a, err := findAssignment(className, assignmentName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if a.StarterRepo != "" {
+			if ignore, err = starterFingerprints(a); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	var names []string
+	var prints []fingerprints
+	var skipped []string
+	for _, username := range usernames {
+		if !isCloned(username) {
+			skipped = append(skipped, username)
+			continue
+		}
+		fp, err := repoFingerprints(username)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %v", username, err)
+		}
+		names = append(names, username)
+		prints = append(prints, fp)
+	}
+
+	common := make(map[uint64]int)
+	if ignore == nil && len(prints) >= 4 {
+		for _, fp := range prints {
+			for h := range fp {
+				common[h]++
+			}
+		}
+	}
+	for i := range prints {
+		for h := range prints[i] {
+			if _, ok := ignore[h]; ok || common[h]*2 > len(prints) {
+				delete(prints[i], h)
+			}
+		}
+	}
+
+	var pairs []similarPair
+	for i := range prints {
+		for j := i + 1; j < len(prints); j++ {
+			a, b := prints[i], prints[j]
+			if len(a) == 0 || len(b) == 0 {
+				continue
+			}
+			var shared []uint64
+			for h := range a {
+				if _, ok := b[h]; ok {
+					shared = append(shared, h)
+				}
+			}
+			p := similarPair{
+				A: names[i], B: names[j],
+				ScoreA: float64(len(shared)) / float64(len(a)),
+				ScoreB: float64(len(shared)) / float64(len(b)),
+				Shared: len(shared),
+			}
+			if p.score() < threshold {
+				continue
+			}
+			p.Regions = matchRegions(a, b, shared)
+			pairs = append(pairs, p)
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].score() > pairs[j].score() })
+	return pairs, skipped, nil
+}
+
+// similarityReport lists suspicious pairs with their top matching regions.
+func similarityReport(className, assignmentName string, threshold float64) (string, error) {
+	pairs, skipped, err := classSimilarity(className, assignmentName, threshold)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Similarity in %s", className))
+	if assignmentName != "" {
+		if a, err := findAssignment(className, assignmentName); err == nil && a.StarterRepo != "" {
+			sb.WriteString(fmt.Sprintf(" (excluding %s starter code)", a.Name))
+		}
+	}
+	sb.WriteString(fmt.Sprintf(", threshold %.0f%%:\n", threshold*100))
+	sb.WriteString("----------------------------------------\n")
+	if len(pairs) == 0 {
+		sb.WriteString("No pairs above the threshold.\n")
+	}
+	for _, p := range pairs {
+		sb.WriteString(fmt.Sprintf("%s ↔ %s: %.0f%% / %.0f%% (%d shared fingerprints)\n",
+			p.A, p.B, p.ScoreA*100, p.ScoreB*100, p.Shared))
+		for i, r := range p.Regions {
+			if i == 5 {
+				sb.WriteString(fmt.Sprintf("    ... %d more regions\n", len(p.Regions)-i))
+				break
+			}
+			sb.WriteString(fmt.Sprintf("    %s:%d-%d ↔ %s:%d-%d\n", r.FileA, r.StartA, r.EndA, r.FileB, r.StartB, r.EndB))
+		}
+	}
+	if len(skipped) > 0 {
+		sb.WriteString(fmt.Sprintf("\nNot cloned (skipped): %s\n", strings.Join(skipped, ", ")))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// classIDByName looks up a class inside a transaction.
+func classIDByName(tx *sql.Tx, name string) (int, error) {
+	var id int
+	if err := tx.QueryRow("SELECT id FROM classes WHERE name = ?", name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("class not found: %s", name)
+	}
+	return id, nil
+}
+
+// studentAssignmentTables hold a student's own rows for an assignment.
+// grades is left out: its rows also point at a rubric criterion.
+var studentAssignmentTables = []string{"deadline_snapshots", "check_results", "grade_comments", "feedback_issues", "review_notes"}
+
+// moveAssignmentRows re-keys a moving student's grades, check results and
+// other assignment records to the assignments and criteria of the same name
+// in the new class, so they show up there and outlive the old class. It
+// returns the old assignments whose records had nowhere to go.
+func moveAssignmentRows(tx *sql.Tx, username string, fromID, toID int) ([]string, error) {
+	rows, err := tx.Query(`
+		SELECT f.id, f.name, t.id
+		FROM assignments f
+		LEFT JOIN assignments t ON t.class_id = ? AND t.name = f.name
+		WHERE f.class_id = ?
+		ORDER BY f.name`,
+		toID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	type pair struct {
+		from int
+		name string
+		to   sql.NullInt64
+	}
+	var pairs []pair
+	for rows.Next() {
+		var p pair
+		if err := rows.Scan(&p.from, &p.name, &p.to); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var left []string
+	for _, p := range pairs {
+		if p.to.Valid {
+			// The moved record replaces any the student left in the new
+			// class on an earlier visit.
+			for _, table := range studentAssignmentTables {
+				if _, err := tx.Exec("UPDATE OR REPLACE "+table+" SET assignment_id = ? WHERE assignment_id = ? AND username = ?",
+					p.to.Int64, p.from, username); err != nil {
+					return nil, fmt.Errorf("failed to move %s: %v", table, err)
+				}
+			}
+			_, err := tx.Exec(`
+				UPDATE OR REPLACE grades SET assignment_id = ?, criterion_id = (
+					SELECT t.id FROM rubric_criteria t JOIN rubric_criteria f ON f.name = t.name
+					WHERE t.assignment_id = ? AND f.id = grades.criterion_id)
+				WHERE assignment_id = ? AND username = ? AND criterion_id IN (
+					SELECT f.id FROM rubric_criteria f JOIN rubric_criteria t ON t.name = f.name
+					WHERE f.assignment_id = ? AND t.assignment_id = ?)`,
+				p.to.Int64, p.to.Int64, p.from, username, p.from, p.to.Int64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to move grades: %v", err)
+			}
+		}
+
+		var remaining int
+		for _, table := range append([]string{"grades"}, studentAssignmentTables...) {
+			var n int
+			if err := tx.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE assignment_id = ? AND username = ?", p.from, username).Scan(&n); err != nil {
+				return nil, err
+			}
+			remaining += n
+		}
+		if remaining > 0 {
+			left = append(left, p.name)
+		}
+	}
+	return left, nil
+}
+
+// transferStudents moves (or, with duplicate set, copies) students from one
+// class to another. Per-student history is keyed by username, so it follows
+// the student either way. A move also takes the student's assignment
+// records along where the new class has a matching assignment; a copy
+// leaves them with the old class.
+func transferStudents(usernames []string, fromClass, toClass string, duplicate bool) (string, error) {
+	if fromClass == toClass {
+		return "", fmt.Errorf("source and target class are the same: %s", fromClass)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	fromID, err := classIDByName(tx, fromClass)
+	if err != nil {
+		return "", err
+	}
+	toID, err := classIDByName(tx, toClass)
+	if err != nil {
+		return "", err
+	}
+
+	verb := "Moved"
+	if duplicate {
+		verb = "Copied"
+	}
+
+	var sb strings.Builder
+	for _, username := range usernames {
+		var verified bool
+		err := tx.QueryRow("SELECT verified FROM students WHERE username = ? AND class_id = ? AND archived = 0",
+			username, fromID).Scan(&verified)
+		if err != nil {
+			return "", fmt.Errorf("student %s not found in class: %s", username, fromClass)
+		}
+
+		var exists int
+		tx.QueryRow("SELECT COUNT(*) FROM students WHERE username = ? AND class_id = ?",
+			username, toID).Scan(&exists)
+		if exists > 0 {
+			return "", fmt.Errorf("student %s is already in class: %s", username, toClass)
+		}
+
+		if duplicate {
+			_, err = tx.Exec("INSERT INTO students (username, class_id, verified) VALUES (?, ?, ?)",
+				username, toID, verified)
+		} else {
+			_, err = tx.Exec("UPDATE students SET class_id = ? WHERE username = ? AND class_id = ?",
+				toID, username, fromID)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to update %s: %v", username, err)
+		}
+		sb.WriteString(fmt.Sprintf("%s student: %s from %s to %s\n", verb, username, fromClass, toClass))
+		if duplicate {
+			continue
+		}
+		left, err := moveAssignmentRows(tx, username, fromID, toID)
+		if err != nil {
+			return "", fmt.Errorf("failed to move records for %s: %v", username, err)
+		}
+		if len(left) > 0 {
+			sb.WriteString(fmt.Sprintf("  Records for %s stay with %s: %s has no assignment or criterion of the same name\n",
+				strings.Join(left, ", "), fromClass, toClass))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit changes: %v", err)
+	}
+	return sb.String(), nil
+}
+
+// classStudents returns the active (non-archived) students of an active
+// class, ordered by username.
+func classStudents(className string) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT s.username
+		FROM students s
+		JOIN classes c ON s.class_id = c.id
+		WHERE c.name = ? AND c.archived = 0 AND s.archived = 0
+		ORDER BY s.username`,
+		className)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usernames []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		usernames = append(usernames, username)
+	}
+	return usernames, rows.Err()
+}
+
+// activeClasses returns the names of all non-archived classes.
+func activeClasses() ([]string, error) {
+	rows, err := db.Query("SELECT name FROM classes WHERE archived = 0 ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// student is one row of a class roster.
+type student struct {
+	Username string `json:"username"`
+	Verified bool   `json:"verified"`
+}
+
+// classRoster returns the active students of a class with their details.
+func classRoster(className string) ([]student, error) {
+	rows, err := db.Query(`
+		SELECT s.username, s.verified
+		FROM students s
+		JOIN classes c ON s.class_id = c.id
+		WHERE c.name = ? AND c.archived = 0 AND s.archived = 0
+		ORDER BY s.username`,
+		className)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roster []student
+	for rows.Next() {
+		var st student
+		if err := rows.Scan(&st.Username, &st.Verified); err != nil {
+			return nil, err
+		}
+		roster = append(roster, st)
+	}
+	return roster, rows.Err()
+}
+
+// classExists reports whether an active class with this name exists.
+func classExists(className string) bool {
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM classes WHERE name = ? AND archived = 0", className).Scan(&n)
+	return n > 0
+}
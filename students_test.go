@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMoveStudentKeepsGrades(t *testing.T) {
+	newTestDB(t)
+	for _, name := range []string{"fall", "spring"} {
+		if _, err := db.Exec("INSERT INTO classes (name) VALUES (?)", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checks := []studentCheck{{username: "student1", userExists: true, repoExists: true}}
+	for _, step := range []func() (string, error){
+		func() (string, error) { return addStudents("fall", checks, false) },
+		func() (string, error) { return addAssignment("fall", "portfolio") },
+		func() (string, error) { return addCriterion("fall", "portfolio", "Layout", 10) },
+		func() (string, error) { return addAssignment("fall", "essay") },
+		func() (string, error) { return addCriterion("fall", "essay", "Writing", 5) },
+		func() (string, error) { return addAssignment("spring", "portfolio") },
+		func() (string, error) { return addCriterion("spring", "portfolio", "Layout", 10) },
+		func() (string, error) { return gradeStudent("fall", "portfolio", "student1", "Layout", "7", "tidy") },
+		func() (string, error) { return gradeStudent("fall", "essay", "student1", "Writing", "4", "") },
+	} {
+		if _, err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := transferStudents([]string{"student1"}, "fall", "spring", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Records for essay stay with fall") {
+		t.Errorf("move output %q doesn't mention the essay grade left behind", out)
+	}
+
+	gradebook := func() []string {
+		t.Helper()
+		gb, err := classGradebook("spring")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(gb.rows) != 1 {
+			t.Fatalf("spring gradebook rows = %v, want student1 only", gb.rows)
+		}
+		return gb.rows[0]
+	}
+	want := []string{"student1", "7", "7", "10"}
+	if got := gradebook(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("gradebook after move = %v, want %v", got, want)
+	}
+
+	if _, err := deleteClass("fall"); err != nil {
+		t.Fatal(err)
+	}
+	if got := gradebook(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("gradebook after deleting the old class = %v, want %v", got, want)
+	}
+	a, err := findAssignment("spring", "portfolio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grades, err := assignmentGrades(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := grades["student1"]; g == nil || len(g.Comments) != 1 {
+		t.Errorf("moved grade = %+v, want the criterion comment kept", g)
+	}
+}
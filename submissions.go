@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Submission statuses for an assignment.
+const (
+	submissionNotStarted = "not_started" // no commits beyond the starter
+	submissionInProgress = "in_progress" // commits, and the deadline hasn't passed (or there is none)
+	submissionOnTime     = "on_time"     // graded on a commit from before the deadline
+	submissionLate       = "late"        // graded on a commit from after the deadline
+	submissionError      = "error"       // the clone could not be read
+)
+
+// submission is a student's progress on one assignment, worked out from
+// the commit times in their clone. LateBy is how long after the deadline
+// the last commit was made, whenever there are late commits; an on-time
+// submission can have some when it was graded on its deadline snapshot.
+type submission struct {
+	Username    string
+	Status      string
+	Commits     int
+	LastCommit  time.Time
+	LateCommits int // commits after the deadline
+	LateBy      time.Duration
+	Error       string
+}
+
+// MarshalJSON uses the same field names as the csv/tsv export.
+func (s submission) MarshalJSON() ([]byte, error) {
+	type submissionJSON struct {
+		Username    string     `json:"username"`
+		Status      string     `json:"status"`
+		Commits     int        `json:"commits"`
+		LastCommit  *time.Time `json:"last_commit"`
+		LateCommits int        `json:"late_commits"`
+		LateHours   *float64   `json:"late_hours"`
+		Error       string     `json:"error,omitempty"`
+	}
+
+	out := submissionJSON{
+		Username:    s.Username,
+		Status:      s.Status,
+		Commits:     s.Commits,
+		LateCommits: s.LateCommits,
+		Error:       s.Error,
+	}
+	if !s.LastCommit.IsZero() {
+		last := s.LastCommit.UTC()
+		out.LastCommit = &last
+	}
+	if s.LateCommits > 0 {
+		hours := s.LateBy.Hours()
+		out.LateHours = &hours
+	}
+	return json.Marshal(out)
+}
+
+// studentSubmission classifies a student's work on an assignment against
+// the deadline. Commits count from base (the starter commit) when the
+// repository descends from it, and otherwise from when the assignment was
+// set, so older work in a shared repository isn't mistaken for this
+// assignment's. The status is that of the commit being graded: the deadline
+// snapshot if one was taken, or else the latest commit.
+func studentSubmission(username string, a assignment, base string) submission {
+	s := submission{Username: username}
+	fail := func(err error) submission {
+		s.Status, s.Error = submissionError, err.Error()
+		return s
+	}
+
+	if !isCloned(username) {
+		return fail(fmt.Errorf("not cloned"))
+	}
+	head := remoteHead(username)
+	exclude, since := "", a.AssignedAt
+	if base != "" {
+		if err := fetchStarterInto(username, a); err != nil {
+			return fail(err)
+		}
+		if ok, _ := vcs.IsAncestor(username, base, head); ok {
+			exclude, since = base, time.Time{}
+		}
+	}
+	switch {
+	case exclude != "":
+	case since.IsZero() && isShallow(username):
+		return fail(errShallowHistory)
+	case since.IsZero():
+		return fail(fmt.Errorf("no starter this repository descends from and no assigned date; set one with scv set-assigned"))
+	case !hasHistorySince(username, since):
+		return fail(errShallowHistory)
+	}
+	commits, err := vcs.Commits(username, head, exclude)
+	if err != nil {
+		return fail(err)
+	}
+
+	var lastLate time.Time
+	for _, c := range commits {
+		t := c.Committed
+		if t.Before(since) {
+			continue
+		}
+		s.Commits++
+		if t.After(s.LastCommit) {
+			s.LastCommit = t
+		}
+		if !a.DueAt.IsZero() && t.After(a.DueAt) {
+			s.LateCommits++
+			if t.After(lastLate) {
+				lastLate = t
+			}
+		}
+	}
+	if s.LateCommits > 0 {
+		s.LateBy = lastLate.Sub(a.DueAt)
+	}
+
+	_, err = vcs.Resolve(username, deadlineTag(a))
+	snapshot := err == nil
+	switch {
+	case s.Commits == 0:
+		s.Status = submissionNotStarted
+	case a.DueAt.IsZero() || time.Now().Before(a.DueAt):
+		s.Status = submissionInProgress
+	case snapshot || s.LateCommits == 0:
+		s.Status = submissionOnTime
+	default:
+		s.Status = submissionLate
+	}
+	return s
+}
+
+// classSubmissions works out every student's status for an assignment.
+// Pull Changes first for up-to-date results.
+func classSubmissions(className, assignmentName string) (assignment, []submission, error) {
+	a, err := findAssignment(className, assignmentName)
+	if err != nil {
+		return a, nil, err
+	}
+	var base string
+	if a.StarterRepo != "" {
+		if base, err = syncStarter(a); err != nil {
+			return a, nil, err
+		}
+	}
+	usernames, err := classStudents(className)
+	if err != nil {
+		return a, nil, err
+	}
+
+	var subs []submission
+	for _, username := range usernames {
+		subs = append(subs, studentSubmission(username, a, base))
+	}
+	return a, subs, nil
+}
+
+func submissionTable(subs []submission) table {
+	t := table{header: []string{"username", "status", "commits", "last_commit", "late_commits", "late_hours"}}
+	for _, s := range subs {
+		lastCommit, lateHours := "", ""
+		if !s.LastCommit.IsZero() {
+			lastCommit = s.LastCommit.UTC().Format(time.RFC3339)
+		}
+		if s.LateCommits > 0 {
+			lateHours = strconv.FormatFloat(s.LateBy.Hours(), 'f', 1, 64)
+		}
+		t.rows = append(t.rows, []string{s.Username, s.Status, strconv.Itoa(s.Commits), lastCommit, strconv.Itoa(s.LateCommits), lateHours})
+	}
+	return t
+}
+
+// submissionText is the class overview as shown in the TUI.
+func submissionText(a assignment, subs []submission) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Submissions for %s", a.Name))
+	if !a.DueAt.IsZero() {
+		sb.WriteString(" (due " + a.DueAt.Local().Format(deadlineLayout) + ")")
+	}
+	sb.WriteString(":\n----------------------------------------\n")
+
+	counts := make(map[string]int)
+	for _, s := range subs {
+		counts[s.Status]++
+		switch s.Status {
+		case submissionError:
+			sb.WriteString(fmt.Sprintf("%s %s: Error - %s\n", errorStyle.Render(iconError), errorStyle.Render(s.Username), s.Error))
+		case submissionNotStarted:
+			sb.WriteString(fmt.Sprintf("%s %s: Not started\n", errorStyle.Render(iconError), errorStyle.Render(s.Username)))
+		case submissionInProgress:
+			sb.WriteString(fmt.Sprintf("%s %s: In progress, %d commits, last %s ago\n", warningStyle.Render(iconWarning),
+				warningStyle.Render(s.Username), s.Commits, formatDuration(time.Since(s.LastCommit))))
+		case submissionOnTime:
+			line := fmt.Sprintf("%s %s: Submitted on time, %d commits", successStyle.Render(iconSuccess), successStyle.Render(s.Username), s.Commits)
+			if s.LateCommits > 0 {
+				line += fmt.Sprintf(" (graded on the snapshot; %d commits after the deadline, last %s late)", s.LateCommits, formatDuration(s.LateBy))
+			}
+			sb.WriteString(line + "\n")
+		case submissionLate:
+			sb.WriteString(fmt.Sprintf("%s %s: Submitted %s late, %d commits\n", warningStyle.Render(iconWarning),
+				warningStyle.Render(s.Username), formatDuration(s.LateBy), s.Commits))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("\n%d on time, %d late, %d in progress, %d not started",
+		counts[submissionOnTime], counts[submissionLate], counts[submissionInProgress], counts[submissionNotStarted]))
+	if counts[submissionError] > 0 {
+		sb.WriteString(fmt.Sprintf(", %d errors", counts[submissionError]))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
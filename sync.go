@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// defaultSyncInterval keeps stored activity younger than activityMaxAge,
+// so the TUI never has to wait on GitHub while the daemon is running.
+const defaultSyncInterval = 15 * time.Minute
+
+// syncResult counts what happened while syncing one class.
+type syncResult struct {
+	className string
+	students  int
+	pulled    int
+	fetched   int
+	errors    []string
+}
+
+// syncClass pulls every cloned repository in a class, following the class's
+// pull policy and clone options, and fetches activity for every student.
+// The reset and reclone policies are only applied with allowReset, since
+// nobody is watching a sync. Failures and skipped clones are collected
+// rather than aborting the run.
+func syncClass(className string, allowReset bool) (syncResult, error) {
+	result := syncResult{className: className}
+
+	usernames, err := classStudents(className)
+	if err != nil {
+		return result, err
+	}
+	result.students = len(usernames)
+	policy, err := classPullPolicy(className)
+	if err != nil {
+		return result, err
+	}
+	options, err := classCloneOptions(className, "")
+	if err != nil {
+		return result, err
+	}
+	repo, err := classRepoPattern(className)
+	if err != nil {
+		return result, err
+	}
+	if !allowReset && (policy == pullReset || policy == pullReclone) {
+		result.errors = append(result.errors, fmt.Sprintf("pull policy %s needs scv daemon --allow-reset; skipping instead", policy))
+		policy = pullSkip
+	}
+
+	for _, username := range usernames {
+		switch r := pullWithPolicy(username, policy, repo, options, false); r.Action {
+		case pullNotCloned:
+		case pullFailed, pullSkipped:
+			result.errors = append(result.errors, fmt.Sprintf("pull %s: %s (%s)", username, r.Action, r.Detail))
+		default:
+			result.pulled++
+		}
+
+		if err := fetchActivity(username); err != nil {
+			result.errors = append(result.errors, fmt.Sprintf("activity %s: %v", username, err))
+		} else {
+			result.fetched++
+		}
+	}
+	return result, nil
+}
+
+// syncAll syncs every active class and records each run in sync_runs.
+func syncAll(allowReset bool) error {
+	classes, err := activeClasses()
+	if err != nil {
+		return err
+	}
+
+	for _, className := range classes {
+		started := time.Now().UTC()
+		result, err := syncClass(className, allowReset)
+		if err != nil {
+			result.errors = append(result.errors, err.Error())
+		}
+
+		var lastError *string
+		if n := len(result.errors); n > 0 {
+			lastError = &result.errors[n-1]
+		}
+
+		_, err = db.Exec(`
+			INSERT INTO sync_runs (class_name, started_at, finished_at, students, pulled, fetched, errors, last_error)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			className, started, time.Now().UTC(), result.students, result.pulled, result.fetched,
+			len(result.errors), lastError)
+		if err != nil {
+			return fmt.Errorf("failed to record sync run: %v", err)
+		}
+
+		log.Printf("synced %s: %d students, %d pulled, %d fetched, %d errors",
+			className, result.students, result.pulled, result.fetched, len(result.errors))
+		for _, e := range result.errors {
+			log.Printf("  %s", e)
+		}
+	}
+	return nil
+}
+
+// runDaemon syncs immediately and then every interval until ctx is done.
+func runDaemon(ctx context.Context, interval time.Duration, allowReset bool) error {
+	log.Printf("sync daemon started, interval %s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := syncAll(allowReset); err != nil {
+			log.Printf("sync failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("sync daemon stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncStatus reports the most recent sync run for each class.
+func syncStatus() (string, error) {
+	rows, err := db.Query(`
+		SELECT r.class_name, r.finished_at, r.students, r.pulled, r.fetched, r.errors, r.last_error
+		FROM sync_runs r
+		WHERE r.id = (SELECT MAX(id) FROM sync_runs WHERE class_name = r.class_name)
+		ORDER BY r.class_name`)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var sb strings.Builder
+	sb.WriteString("Sync Status:\n")
+	sb.WriteString("----------------------------------------\n")
+	found := false
+	for rows.Next() {
+		var (
+			className                          string
+			finished                           time.Time
+			students, pulled, fetched, nErrors int
+			lastError                          sql.NullString
+		)
+		if err := rows.Scan(&className, &finished, &students, &pulled, &fetched, &nErrors, &lastError); err != nil {
+			return "", err
+		}
+		found = true
+
+		line := fmt.Sprintf("%s: synced %s ago (%d students, %d pulled, %d fetched)",
+			className, formatDuration(time.Since(finished)), students, pulled, fetched)
+		switch {
+		case nErrors > 0:
+			sb.WriteString(fmt.Sprintf("%s %s, %d errors, last: %s\n",
+				warningStyle.Render(iconWarning), line, nErrors, lastError.String))
+		default:
+			sb.WriteString(fmt.Sprintf("%s %s\n", successStyle.Render(iconSuccess), line))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if !found {
+		sb.WriteString("No sync has run yet. Start one with: scv daemon\n")
+	}
+	return sb.String(), nil
+}
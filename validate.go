@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// githubAPI is the base URL for all GitHub REST calls. SCV_GITHUB_API
+// points it at a stand-in server, e.g. to try out send-feedback.
+var githubAPI = strings.TrimSuffix(cmp.Or(os.Getenv("SCV_GITHUB_API"), "https://api.github.com"), "/")
+
+// studentCheck is the result of validating one username against GitHub.
+type studentCheck struct {
+	username   string
+	repo       string
+	userExists bool
+	repoExists bool
+	err        error
+}
+
+func (c studentCheck) valid() bool {
+	return c.err == nil && c.userExists && c.repoExists
+}
+
+// problem describes why a check failed, for the validation report.
+func (c studentCheck) problem() string {
+	switch {
+	case c.err != nil:
+		return fmt.Sprintf("could not verify (%v)", c.err)
+	case !c.userExists:
+		return "GitHub user not found"
+	case !c.repoExists:
+		return fmt.Sprintf("repository %s not found or not accessible", c.repo)
+	}
+	return ""
+}
+
+// defaultRepoPattern is the GitHub Pages user site, which classes expect
+// unless they set their own naming convention.
+const defaultRepoPattern repoPattern = "{user}.github.io"
+
+// repoPattern names the repository each student in a class is expected to
+// have; {user} stands for the username.
+type repoPattern string
+
+func (p repoPattern) name(username string) string {
+	return strings.ReplaceAll(string(p), "{user}", username)
+}
+
+var validRepoName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+func (p repoPattern) validate() error {
+	if name := p.name("user"); !validRepoName.MatchString(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid repo pattern %q (want a repository name, with {user} for the username)", p)
+	}
+	return nil
+}
+
+// classRepoPattern is the repository naming convention of a class.
+func classRepoPattern(className string) (repoPattern, error) {
+	var p repoPattern
+	err := db.QueryRow("SELECT repo_pattern FROM classes WHERE name = ? AND archived = 0", className).Scan(&p)
+	if err != nil {
+		return "", fmt.Errorf("class not found: %s", className)
+	}
+	if p == "" {
+		p = defaultRepoPattern
+	}
+	return p, nil
+}
+
+func setRepoPattern(className string, p repoPattern) (string, error) {
+	if err := p.validate(); err != nil {
+		return "", err
+	}
+	res, err := db.Exec("UPDATE classes SET repo_pattern = ? WHERE name = ? AND archived = 0", p, className)
+	if err != nil {
+		return "", fmt.Errorf("failed to set repo pattern: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "", fmt.Errorf("class not found: %s", className)
+	}
+	return fmt.Sprintf("Repo pattern for %s: %s (e.g. %s)\n", className, p, p.name("octocat")), nil
+}
+
+// studentRepoPattern is the naming convention of the classes a student is
+// in, for commands that only name the student. Classes that disagree have
+// to be told apart by the caller.
+func studentRepoPattern(username string) (repoPattern, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT c.repo_pattern
+		FROM students s
+		JOIN classes c ON s.class_id = c.id
+		WHERE s.username = ? AND c.archived = 0 AND s.archived = 0`, username)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var patterns []repoPattern
+	for rows.Next() {
+		var p repoPattern
+		if err := rows.Scan(&p); err != nil {
+			return "", err
+		}
+		patterns = append(patterns, p)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	switch len(patterns) {
+	case 0:
+		return defaultRepoPattern, nil
+	case 1:
+		return patterns[0], nil
+	}
+	return "", fmt.Errorf("%s is in classes with different repo patterns; name the class with --class", username)
+}
+
+// errGithubNotFound is returned by githubJSON for a 404.
+var errGithubNotFound = errors.New("not found on GitHub")
+
+// githubJSON sends a request to the GitHub API (authenticated when a token
+// is available), encoding body (if any) as JSON and decoding the response
+// into out.
+func githubJSON(method, url string, body, out any) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, payload)
+	if err != nil {
+		return err
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errGithubNotFound
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Message != "" {
+			return fmt.Errorf("GitHub API returned status: %s (%s)", resp.Status, apiErr.Message)
+		}
+		return fmt.Errorf("GitHub API returned status: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// existsOnGithub reports whether a GitHub API URL exists, treating
+// anything other than success and 404 (rate limits, outages) as an error.
+func existsOnGithub(url string) (bool, error) {
+	err := githubJSON("GET", url, nil, nil)
+	if errors.Is(err, errGithubNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func validateStudent(username string, repo repoPattern) studentCheck {
+	check := studentCheck{username: username, repo: repo.name(username)}
+
+	check.userExists, check.err = existsOnGithub(fmt.Sprintf("%s/users/%s", githubAPI, username))
+	if check.err != nil || !check.userExists {
+		return check
+	}
+
+	check.repoExists, check.err = existsOnGithub(fmt.Sprintf("%s/repos/%s/%s", githubAPI, username, check.repo))
+	return check
+}
+
+func validateStudents(usernames []string, repo repoPattern) []studentCheck {
+	checks := make([]studentCheck, 0, len(usernames))
+	for _, username := range usernames {
+		checks = append(checks, validateStudent(username, repo))
+	}
+	return checks
+}
+
+// validationReport lists every check that failed.
+func validationReport(checks []studentCheck) string {
+	var sb strings.Builder
+	sb.WriteString("The following students could not be verified:\n")
+	for _, c := range checks {
+		if c.valid() {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s %s: %s\n",
+			errorStyle.Render(iconError),
+			errorStyle.Render(c.username),
+			c.problem(),
+		))
+	}
+	return sb.String()
+}
+
+// addStudents inserts the checked students into a class. Students that
+// failed validation are skipped unless includeUnverified is set, in which
+// case they are stored with verified = 0.
+func addStudents(className string, checks []studentCheck, includeUnverified bool) (string, error) {
+	var classID int
+	err := db.QueryRow("SELECT id FROM classes WHERE name = ?", className).Scan(&classID)
+	if err != nil {
+		return "", fmt.Errorf("class not found: %s", className)
+	}
+
+	var sb strings.Builder
+	for _, c := range checks {
+		if !c.valid() && !includeUnverified {
+			sb.WriteString(fmt.Sprintf("Skipped student: %s (%s)\n", c.username, c.problem()))
+			continue
+		}
+
+		// Re-adding an archived student restores them.
+		_, err := db.Exec(`
+			INSERT INTO students (username, class_id, verified) VALUES (?, ?, ?)
+			ON CONFLICT(username, class_id) DO UPDATE SET archived = 0`,
+			c.username, classID, c.valid())
+		if err != nil {
+			return "", err
+		}
+
+		if c.valid() {
+			sb.WriteString(fmt.Sprintf("Added student: %s to class: %s\n", c.username, className))
+		} else {
+			sb.WriteString(fmt.Sprintf("Added student: %s to class: %s (unverified)\n", c.username, className))
+		}
+	}
+	return sb.String(), nil
+}
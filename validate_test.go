@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestRepoPattern(t *testing.T) {
+	newTestDB(t)
+	if _, err := db.Exec("INSERT INTO classes (name) VALUES (?)", "section1"); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := classRepoPattern("section1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo != defaultRepoPattern {
+		t.Errorf("new class pattern = %q, want %q", repo, defaultRepoPattern)
+	}
+	if got, want := repo.pagesURL("octocat"), "https://octocat.github.io"; got != want {
+		t.Errorf("user site pages URL = %s, want %s", got, want)
+	}
+
+	for _, bad := range []string{"", "{user}/site", "my site", ".."} {
+		if _, err := setRepoPattern("section1", repoPattern(bad)); err == nil {
+			t.Errorf("setRepoPattern(%q) succeeded", bad)
+		}
+	}
+	if _, err := setRepoPattern("section1", "web-{user}"); err != nil {
+		t.Fatal(err)
+	}
+	if repo, err = classRepoPattern("section1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := repo.url("octocat"), "https://github.com/octocat/web-octocat"; got != want {
+		t.Errorf("repo URL = %s, want %s", got, want)
+	}
+	if got, want := repo.pagesURL("octocat"), "https://octocat.github.io/web-octocat/"; got != want {
+		t.Errorf("project site pages URL = %s, want %s", got, want)
+	}
+}
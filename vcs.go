@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// vcsBackend is how scv works with student clones. Each method takes the
+// clone's directory, so backends can be pointed at any repository,
+// including local bare repositories standing in for GitHub.
+type vcsBackend interface {
+	// Clone clones url into dir with the given options.
+	Clone(url, dir string, o cloneOptions) error
+	// Fetch updates origin's branches, pruning deleted ones, and makes sure
+	// origin/HEAD names the remote's default branch.
+	Fetch(dir string) error
+	// Head returns the SHA of the checked-out commit, without looking at
+	// the working tree.
+	Head(dir string) (string, error)
+	// Status describes the checked-out branch and uncommitted changes.
+	Status(dir string) (cloneStatus, error)
+	// Divergence compares HEAD with the branch pulls come from.
+	Divergence(dir string) (divergence, error)
+	// FastForward moves the checked-out branch forward to ref, failing if
+	// the two have diverged.
+	FastForward(dir, ref string) error
+	// Reset checks out ref's branch, matching ref exactly, and removes
+	// untracked files.
+	Reset(dir, ref string) error
+	// Discard throws away uncommitted changes to tracked files.
+	Discard(dir string) error
+	// SetRemoteURL points origin at url.
+	SetRemoteURL(dir, url string) error
+	// Configure applies the options that can change after cloning.
+	Configure(dir string, o cloneOptions) error
+	// Log returns up to max commits reachable from HEAD, newest first;
+	// with a path, only commits that changed it.
+	Log(dir, path string, max int) ([]commitInfo, error)
+	// RemoteURL returns origin's URL.
+	RemoteURL(dir string) (string, error)
+	// Resolve returns the SHA of the commit rev (a ref, tag or SHA) names,
+	// failing if the clone doesn't have it.
+	Resolve(dir, rev string) (string, error)
+	// IsAncestor reports whether ancestor is in rev's history.
+	IsAncestor(dir, ancestor, rev string) (bool, error)
+	// Commits returns the commits in rev's history that aren't in
+	// exclude's (when given), in git log's order: newest commit date first,
+	// children before parents.
+	Commits(dir, rev, exclude string) ([]commitInfo, error)
+	// Changed reports whether path differs between commits from and to.
+	Changed(dir, path, from, to string) (bool, error)
+	// Tag points the local tag name at sha, or deletes it when sha is
+	// empty.
+	Tag(dir, name, sha string) error
+	// FetchRefs fetches refspecs from url without adding it as a remote or
+	// fetching its tags.
+	FetchRefs(dir, url string, refspecs ...string) error
+	// Shallow reports whether the clone is shallow and, once Deepen has
+	// fetched its history back to a date, that date.
+	Shallow(dir string) (bool, time.Time, error)
+	// Deepen fetches a shallow clone's history back to since, or all of it
+	// when since is zero.
+	Deepen(dir string, since time.Time) error
+	// Stash saves uncommitted changes, untracked files included.
+	Stash(dir, message string) error
+	// Stashes returns how many stashes the clone has.
+	Stashes(dir string) (int, error)
+}
+
+// cloneStatus is the working state of a clone.
+type cloneStatus struct {
+	Branch    string   // empty when HEAD is detached
+	Head      string   // commit SHA
+	Dirty     []string // porcelain status lines, e.g. " M index.html"
+	RemoteURL string
+}
+
+// divergence is how far a clone and its upstream have moved apart.
+type divergence struct {
+	Upstream string // e.g. origin/main
+	Ahead    int    // commits only the clone has
+	Behind   int    // commits only the upstream has
+}
+
+// commitInfo is one commit as Log lists it. Both dates keep the time zone
+// they were recorded in.
+type commitInfo struct {
+	SHA       string
+	Author    string
+	Authored  time.Time
+	Committed time.Time
+	Subject   string
+}
+
+// errVCSUnsupported is returned by a backend for a repository, option or
+// remote it can't handle, such as a partial clone or an SSH remote in
+// go-git, or credentials it doesn't have.
+var errVCSUnsupported = errors.New("not supported by this backend")
+
+// fallbackVCS uses primary, switching to fallback for anything primary
+// doesn't support.
+type fallbackVCS struct {
+	primary, fallback vcsBackend
+}
+
+func (f fallbackVCS) Clone(url, dir string, o cloneOptions) error {
+	if err := f.primary.Clone(url, dir, o); !errors.Is(err, errVCSUnsupported) {
+		return err
+	}
+	return f.fallback.Clone(url, dir, o)
+}
+
+func (f fallbackVCS) Fetch(dir string) error {
+	if err := f.primary.Fetch(dir); !errors.Is(err, errVCSUnsupported) {
+		return err
+	}
+	return f.fallback.Fetch(dir)
+}
+
+func (f fallbackVCS) Head(dir string) (string, error) {
+	if sha, err := f.primary.Head(dir); !errors.Is(err, errVCSUnsupported) {
+		return sha, err
+	}
+	return f.fallback.Head(dir)
+}
+
+func (f fallbackVCS) Status(dir string) (cloneStatus, error) {
+	if s, err := f.primary.Status(dir); !errors.Is(err, errVCSUnsupported) {
+		return s, err
+	}
+	return f.fallback.Status(dir)
+}
+
+func (f fallbackVCS) Divergence(dir string) (divergence, error) {
+	if d, err := f.primary.Divergence(dir); !errors.Is(err, errVCSUnsupported) {
+		return d, err
+	}
+	return f.fallback.Divergence(dir)
+}
+
+func (f fallbackVCS) FastForward(dir, ref string) error {
+	if err := f.primary.FastForward(dir, ref); !errors.Is(err, errVCSUnsupported) {
+		return err
+	}
+	return f.fallback.FastForward(dir, ref)
+}
+
+func (f fallbackVCS) Reset(dir, ref string) error {
+	if err := f.primary.Reset(dir, ref); !errors.Is(err, errVCSUnsupported) {
+		return err
+	}
+	return f.fallback.Reset(dir, ref)
+}
+
+func (f fallbackVCS) Discard(dir string) error {
+	if err := f.primary.Discard(dir); !errors.Is(err, errVCSUnsupported) {
+		return err
+	}
+	return f.fallback.Discard(dir)
+}
+
+func (f fallbackVCS) SetRemoteURL(dir, url string) error {
+	if err := f.primary.SetRemoteURL(dir, url); !errors.Is(err, errVCSUnsupported) {
+		return err
+	}
+	return f.fallback.SetRemoteURL(dir, url)
+}
+
+func (f fallbackVCS) Configure(dir string, o cloneOptions) error {
+	if err := f.primary.Configure(dir, o); !errors.Is(err, errVCSUnsupported) {
+		return err
+	}
+	return f.fallback.Configure(dir, o)
+}
+
+func (f fallbackVCS) Log(dir, path string, max int) ([]commitInfo, error) {
+	if commits, err := f.primary.Log(dir, path, max); !errors.Is(err, errVCSUnsupported) {
+		return commits, err
+	}
+	return f.fallback.Log(dir, path, max)
+}
+
+func (f fallbackVCS) RemoteURL(dir string) (string, error) {
+	if url, err := f.primary.RemoteURL(dir); !errors.Is(err, errVCSUnsupported) {
+		return url, err
+	}
+	return f.fallback.RemoteURL(dir)
+}
+
+func (f fallbackVCS) Resolve(dir, rev string) (string, error) {
+	if sha, err := f.primary.Resolve(dir, rev); !errors.Is(err, errVCSUnsupported) {
+		return sha, err
+	}
+	return f.fallback.Resolve(dir, rev)
+}
+
+func (f fallbackVCS) IsAncestor(dir, ancestor, rev string) (bool, error) {
+	if ok, err := f.primary.IsAncestor(dir, ancestor, rev); !errors.Is(err, errVCSUnsupported) {
+		return ok, err
+	}
+	return f.fallback.IsAncestor(dir, ancestor, rev)
+}
+
+func (f fallbackVCS) Commits(dir, rev, exclude string) ([]commitInfo, error) {
+	if commits, err := f.primary.Commits(dir, rev, exclude); !errors.Is(err, errVCSUnsupported) {
+		return commits, err
+	}
+	return f.fallback.Commits(dir, rev, exclude)
+}
+
+func (f fallbackVCS) Changed(dir, path, from, to string) (bool, error) {
+	if changed, err := f.primary.Changed(dir, path, from, to); !errors.Is(err, errVCSUnsupported) {
+		return changed, err
+	}
+	return f.fallback.Changed(dir, path, from, to)
+}
+
+func (f fallbackVCS) Tag(dir, name, sha string) error {
+	if err := f.primary.Tag(dir, name, sha); !errors.Is(err, errVCSUnsupported) {
+		return err
+	}
+	return f.fallback.Tag(dir, name, sha)
+}
+
+func (f fallbackVCS) FetchRefs(dir, url string, refspecs ...string) error {
+	if err := f.primary.FetchRefs(dir, url, refspecs...); !errors.Is(err, errVCSUnsupported) {
+		return err
+	}
+	return f.fallback.FetchRefs(dir, url, refspecs...)
+}
+
+func (f fallbackVCS) Shallow(dir string) (bool, time.Time, error) {
+	if shallow, since, err := f.primary.Shallow(dir); !errors.Is(err, errVCSUnsupported) {
+		return shallow, since, err
+	}
+	return f.fallback.Shallow(dir)
+}
+
+func (f fallbackVCS) Deepen(dir string, since time.Time) error {
+	if err := f.primary.Deepen(dir, since); !errors.Is(err, errVCSUnsupported) {
+		return err
+	}
+	return f.fallback.Deepen(dir, since)
+}
+
+func (f fallbackVCS) Stash(dir, message string) error {
+	if err := f.primary.Stash(dir, message); !errors.Is(err, errVCSUnsupported) {
+		return err
+	}
+	return f.fallback.Stash(dir, message)
+}
+
+func (f fallbackVCS) Stashes(dir string) (int, error) {
+	if n, err := f.primary.Stashes(dir); !errors.Is(err, errVCSUnsupported) {
+		return n, err
+	}
+	return f.fallback.Stashes(dir)
+}
+
+// vcs is the backend for student clones. By default it works in-process
+// with go-git, so git doesn't need to be installed, and runs the git
+// command for what go-git can't do: sparse and partial clones, SSH remotes,
+// URLs rewritten by git's insteadOf, failed authentication and stashing.
+// SCV_VCS=git always runs the git command instead.
+var vcs = newVCS(os.Getenv("SCV_VCS"))
+
+func newVCS(name string) vcsBackend {
+	if name == "git" {
+		return execVCS{}
+	}
+	return fallbackVCS{primary: goGitVCS{}, fallback: execVCS{}}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// execVCS runs the git command. It supports every clone option, but needs
+// git on PATH.
+type execVCS struct{}
+
+func (execVCS) Clone(url, dir string, o cloneOptions) error {
+	args := []string{"clone", "--quiet"}
+	if o.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(o.Depth))
+	}
+	switch {
+	case o.SingleBranch:
+		args = append(args, "--single-branch")
+	case o.Depth > 0:
+		// --depth implies --single-branch unless told otherwise.
+		args = append(args, "--no-single-branch")
+	}
+	if o.Filter != "" {
+		args = append(args, "--filter="+o.Filter)
+	}
+	if len(o.Sparse) > 0 {
+		args = append(args, "--sparse")
+	}
+	if _, err := git(append(args, url, dir)...); err != nil {
+		return err
+	}
+	if len(o.Sparse) > 0 {
+		_, err := git(append([]string{"-C", dir, "sparse-checkout", "set"}, o.Sparse...)...)
+		return err
+	}
+	return nil
+}
+
+func (execVCS) Fetch(dir string) error {
+	if _, err := git("-C", dir, "fetch", "--quiet", "--prune", "origin"); err != nil {
+		return err
+	}
+	if _, err := git("-C", dir, "symbolic-ref", "-q", "refs/remotes/origin/HEAD"); err != nil {
+		git("-C", dir, "remote", "set-head", "origin", "--auto")
+	}
+	return nil
+}
+
+func (execVCS) Head(dir string) (string, error) {
+	return git("-C", dir, "rev-parse", "HEAD")
+}
+
+func (execVCS) Status(dir string) (cloneStatus, error) {
+	var s cloneStatus
+	s.Branch, _ = git("-C", dir, "symbolic-ref", "-q", "--short", "HEAD")
+	s.Head, _ = git("-C", dir, "rev-parse", "HEAD")
+	s.RemoteURL, _ = git("-C", dir, "config", "--get", "remote.origin.url")
+	out, err := git("-C", dir, "status", "--porcelain")
+	if err != nil {
+		return s, err
+	}
+	if out != "" {
+		s.Dirty = strings.Split(out, "\n")
+	}
+	return s, nil
+}
+
+// upstream is what a clone pulls from: the remote's default branch, or the
+// current branch's upstream when origin/HEAD isn't set.
+func (execVCS) upstream(dir string) (string, error) {
+	if ref, err := git("-C", dir, "symbolic-ref", "-q", "--short", "refs/remotes/origin/HEAD"); err == nil && ref != "" {
+		return ref, nil
+	}
+	if ref, err := git("-C", dir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}"); err == nil && ref != "" {
+		return ref, nil
+	}
+	return "", fmt.Errorf("cannot tell which branch to pull: origin/HEAD is not set")
+}
+
+func (e execVCS) Divergence(dir string) (divergence, error) {
+	var d divergence
+	var err error
+	if d.Upstream, err = e.upstream(dir); err != nil {
+		return d, err
+	}
+	counts, err := git("-C", dir, "rev-list", "--left-right", "--count", "HEAD..."+d.Upstream)
+	if err != nil {
+		return d, err
+	}
+	if fields := strings.Fields(counts); len(fields) == 2 {
+		d.Ahead, _ = strconv.Atoi(fields[0])
+		d.Behind, _ = strconv.Atoi(fields[1])
+	}
+	return d, nil
+}
+
+func (execVCS) FastForward(dir, ref string) error {
+	_, err := git("-C", dir, "merge", "--ff-only", "--quiet", ref)
+	return err
+}
+
+func (execVCS) Reset(dir, ref string) error {
+	branch := strings.TrimPrefix(ref, "origin/")
+	if _, err := git("-C", dir, "checkout", "--quiet", "--force", "-B", branch, ref); err != nil {
+		return err
+	}
+	if _, err := git("-C", dir, "reset", "--quiet", "--hard", ref); err != nil {
+		return err
+	}
+	_, err := git("-C", dir, "clean", "--quiet", "-fd")
+	return err
+}
+
+func (execVCS) Discard(dir string) error {
+	_, err := git("-C", dir, "checkout", "--quiet", ".")
+	return err
+}
+
+func (execVCS) SetRemoteURL(dir, url string) error {
+	_, err := git("-C", dir, "remote", "set-url", "origin", url)
+	return err
+}
+
+func (e execVCS) Configure(dir string, o cloneOptions) error {
+	upstream, err := e.upstream(dir)
+	if err != nil {
+		return err
+	}
+	branch := strings.TrimPrefix(upstream, "origin/")
+	refspec := "+refs/heads/*:refs/remotes/origin/*"
+	if o.SingleBranch {
+		refspec = fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", branch, branch)
+	}
+	if current, _ := git("-C", dir, "config", "--get-all", "remote.origin.fetch"); current != refspec {
+		if _, err := git("-C", dir, "config", "--replace-all", "remote.origin.fetch", refspec); err != nil {
+			return err
+		}
+	}
+
+	// Objects already downloaded stay, but later fetches skip what the
+	// filter excludes.
+	if o.Filter != "" {
+		if current, _ := git("-C", dir, "config", "--get", "remote.origin.partialclonefilter"); current != o.Filter {
+			if _, err := git("-C", dir, "config", "remote.origin.promisor", "true"); err != nil {
+				return err
+			}
+			if _, err := git("-C", dir, "config", "remote.origin.partialclonefilter", o.Filter); err != nil {
+				return err
+			}
+		}
+	}
+
+	sparse, _ := git("-C", dir, "config", "--get", "core.sparseCheckout")
+	switch {
+	case len(o.Sparse) > 0:
+		current, _ := git("-C", dir, "sparse-checkout", "list")
+		if sparse != "true" || current != strings.Join(o.Sparse, "\n") {
+			_, err = git(append([]string{"-C", dir, "sparse-checkout", "set"}, o.Sparse...)...)
+		}
+	case sparse == "true":
+		_, err = git("-C", dir, "sparse-checkout", "disable")
+	}
+	return err
+}
+
+// logFormat is what parseLog reads: one commit per line, fields separated
+// by NUL.
+const logFormat = "--format=%H%x00%an%x00%aI%x00%cI%x00%s"
+
+func parseLog(out string) []commitInfo {
+	var commits []commitInfo
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(line, "\x00", 5)
+		if len(parts) != 5 {
+			continue
+		}
+		authored, _ := time.Parse(time.RFC3339, parts[2])
+		committed, _ := time.Parse(time.RFC3339, parts[3])
+		commits = append(commits, commitInfo{SHA: parts[0], Author: parts[1], Authored: authored, Committed: committed, Subject: parts[4]})
+	}
+	return commits
+}
+
+func (execVCS) Log(dir, path string, max int) ([]commitInfo, error) {
+	args := []string{"-C", dir, "log", "--max-count=" + strconv.Itoa(max), logFormat}
+	if path != "" {
+		args = append(args, "--", path)
+	}
+	out, err := git(args...)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return parseLog(out), nil
+}
+
+func (execVCS) RemoteURL(dir string) (string, error) {
+	return git("-C", dir, "config", "--get", "remote.origin.url")
+}
+
+func (execVCS) Resolve(dir, rev string) (string, error) {
+	sha, err := git("-C", dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("%s not found in %s", rev, dir)
+	}
+	return sha, nil
+}
+
+func (e execVCS) IsAncestor(dir, ancestor, rev string) (bool, error) {
+	// merge-base fails both for "no" and for a missing commit, so check the
+	// commits exist first.
+	for _, r := range []string{ancestor, rev} {
+		if _, err := e.Resolve(dir, r); err != nil {
+			return false, err
+		}
+	}
+	_, err := git("-C", dir, "merge-base", "--is-ancestor", ancestor, rev)
+	return err == nil, nil
+}
+
+func (execVCS) Commits(dir, rev, exclude string) ([]commitInfo, error) {
+	args := []string{"-C", dir, "log", logFormat, rev}
+	if exclude != "" {
+		args = append(args, "^"+exclude)
+	}
+	out, err := git(append(args, "--")...)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return parseLog(out), nil
+}
+
+func (execVCS) Changed(dir, path, from, to string) (bool, error) {
+	out, err := git("-C", dir, "diff", "--name-only", "--no-renames", from, to, "--", path)
+	return out != "", err
+}
+
+func (e execVCS) Tag(dir, name, sha string) error {
+	if sha != "" {
+		_, err := git("-C", dir, "tag", "--force", name, sha)
+		return err
+	}
+	if _, err := git("-C", dir, "rev-parse", "--verify", "--quiet", "refs/tags/"+name); err != nil {
+		return nil // nothing to delete
+	}
+	_, err := git("-C", dir, "tag", "--delete", name)
+	return err
+}
+
+func (execVCS) FetchRefs(dir, url string, refspecs ...string) error {
+	_, err := git(append([]string{"-C", dir, "fetch", "--quiet", "--no-tags", url}, refspecs...)...)
+	return err
+}
+
+func (execVCS) Shallow(dir string) (bool, time.Time, error) {
+	out, err := git("-C", dir, "rev-parse", "--is-shallow-repository")
+	if err != nil || out != "true" {
+		return false, time.Time{}, err
+	}
+	var since time.Time
+	if recorded, err := git("-C", dir, "config", "--get", shallowSinceKey); err == nil {
+		since, _ = time.Parse(time.RFC3339, recorded)
+	}
+	return true, since, nil
+}
+
+func (execVCS) Deepen(dir string, since time.Time) error {
+	if !since.IsZero() {
+		date := since.UTC().Format(time.RFC3339)
+		if _, err := git("-C", dir, "fetch", "--quiet", "--shallow-since="+date, "origin"); err == nil {
+			_, err = git("-C", dir, "config", shallowSinceKey, date)
+			return err
+		}
+		// git refuses when a branch has no commits after since.
+	}
+	if _, err := git("-C", dir, "fetch", "--quiet", "--unshallow", "origin"); err != nil {
+		return fmt.Errorf("failed to deepen shallow clone: %v", err)
+	}
+	return nil
+}
+
+func (execVCS) Stash(dir, message string) error {
+	_, err := git("-C", dir, "stash", "push", "--quiet", "--include-untracked", "-m", message)
+	return err
+}
+
+func (execVCS) Stashes(dir string) (int, error) {
+	out, err := git("-C", dir, "stash", "list")
+	if err != nil || out == "" {
+		return 0, err
+	}
+	return len(strings.Split(out, "\n")), nil
+}
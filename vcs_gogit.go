@@ -0,0 +1,725 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// goGitVCS works on clones in-process with go-git, so git doesn't need to
+// be installed. go-git has no partial clones or sparse checkouts; those
+// return errVCSUnsupported.
+type goGitVCS struct{}
+
+// originHead is where the remote's default branch is recorded, as git
+// clone does.
+const originHead = plumbing.ReferenceName("refs/remotes/origin/HEAD")
+
+// goGitAuth uses GITHUB_TOKEN for GitHub over HTTPS, where git would use
+// its credential helper. Other URLs (local paths in particular) need none.
+func goGitAuth(url string) transport.AuthMethod {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" || !strings.HasPrefix(url, "https://github.com/") {
+		return nil
+	}
+	return &githttp.BasicAuth{Username: "x-access-token", Password: token}
+}
+
+// goGitCanReach reports whether go-git can fetch from url on its own: over
+// HTTP(S) or from a local repository. SSH needs git's own setup, and a URL
+// the teacher's git config rewrites with insteadOf is left to git, which
+// applies the rewrite.
+func goGitCanReach(url string) bool {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil || ep.Protocol == "ssh" || ep.Protocol == "git" {
+		return false
+	}
+	if cfg, err := config.LoadConfig(config.GlobalScope); err == nil {
+		for _, rule := range cfg.URLs {
+			if rule.InsteadOf != "" && strings.HasPrefix(url, rule.InsteadOf) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// goGitTransportError describes a failed clone or fetch. Failures that
+// git's credential helper might get past are errVCSUnsupported, so the
+// git command gets a try; GitHub answers "not found" for a private
+// repository asked for without credentials.
+func goGitTransportError(op, url string, err error) error {
+	switch {
+	case errors.Is(err, transport.ErrAuthenticationRequired),
+		errors.Is(err, transport.ErrAuthorizationFailed),
+		errors.Is(err, transport.ErrRepositoryNotFound):
+		return fmt.Errorf("%s %s: %w: %v", op, url, errVCSUnsupported, err)
+	}
+	return fmt.Errorf("%s %s: %v", op, url, err)
+}
+
+// open opens a clone, refusing the ones go-git would misread.
+func (goGitVCS) open(dir string) (*gogit.Repository, error) {
+	r, err := gogit.PlainOpen(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", dir, err)
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+	// git sparse-checkout keeps its settings in config.worktree, which
+	// go-git doesn't read.
+	switch {
+	case cfg.Raw.Section("extensions").Option("worktreeConfig") == "true",
+		cfg.Raw.Section("core").Option("sparseCheckout") == "true",
+		cfg.Raw.Section("extensions").Option("partialClone") != "",
+		cfg.Raw.Section("remote").Subsection("origin").Option("promisor") == "true":
+		return nil, errVCSUnsupported
+	}
+	return r, nil
+}
+
+func remoteURL(r *gogit.Repository) string {
+	remote, err := r.Remote("origin")
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return ""
+	}
+	return remote.Config().URLs[0]
+}
+
+// setOriginHead points origin/HEAD at the remote-tracking copy of branch.
+func setOriginHead(r *gogit.Repository, branch plumbing.ReferenceName) error {
+	target := plumbing.NewRemoteReferenceName("origin", branch.Short())
+	return r.Storer.SetReference(plumbing.NewSymbolicReference(originHead, target))
+}
+
+func (goGitVCS) Clone(url, dir string, o cloneOptions) error {
+	if o.Filter != "" || len(o.Sparse) > 0 || !goGitCanReach(url) {
+		return errVCSUnsupported
+	}
+	// A failed clone removes what it created, so git can try the same dir.
+	r, err := gogit.PlainClone(dir, false, &gogit.CloneOptions{
+		URL:          url,
+		Auth:         goGitAuth(url),
+		Depth:        o.Depth,
+		SingleBranch: o.SingleBranch,
+	})
+	if err != nil {
+		return goGitTransportError("clone", url, err)
+	}
+	head, err := r.Head()
+	if err != nil || !head.Name().IsBranch() {
+		return nil // empty repository
+	}
+	return setOriginHead(r, head.Name())
+}
+
+func (g goGitVCS) Fetch(dir string) error {
+	r, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	url := remoteURL(r)
+	if !goGitCanReach(url) {
+		return errVCSUnsupported
+	}
+	err = r.Fetch(&gogit.FetchOptions{RemoteName: "origin", Auth: goGitAuth(url), Prune: true})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return goGitTransportError("fetch", url, err)
+	}
+
+	if _, err := r.Reference(originHead, false); err == nil {
+		return nil
+	}
+	// Ask the remote which branch is its default, like git remote set-head.
+	remote, err := r.Remote("origin")
+	if err != nil {
+		return err
+	}
+	refs, err := remote.List(&gogit.ListOptions{Auth: goGitAuth(url)})
+	if err != nil {
+		return nil // Divergence reports the missing origin/HEAD
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			return setOriginHead(r, ref.Target())
+		}
+	}
+	return nil
+}
+
+func (g goGitVCS) Head(dir string) (string, error) {
+	r, err := g.open(dir)
+	if err != nil {
+		return "", err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+func (g goGitVCS) Status(dir string) (cloneStatus, error) {
+	var s cloneStatus
+	r, err := g.open(dir)
+	if err != nil {
+		return s, err
+	}
+	s.RemoteURL = remoteURL(r)
+	if head, err := r.Head(); err == nil {
+		s.Head = head.Hash().String()
+		if head.Name().IsBranch() {
+			s.Branch = head.Name().Short()
+		}
+	}
+
+	wt, err := r.Worktree()
+	if err != nil {
+		return s, err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return s, err
+	}
+	for path, fs := range status {
+		if fs.Staging != gogit.Unmodified || fs.Worktree != gogit.Unmodified {
+			s.Dirty = append(s.Dirty, fmt.Sprintf("%c%c %s", fs.Staging, fs.Worktree, path))
+		}
+	}
+	sort.Slice(s.Dirty, func(i, j int) bool { return s.Dirty[i][3:] < s.Dirty[j][3:] })
+	return s, nil
+}
+
+// upstream resolves what a clone pulls from: origin/HEAD, or the current
+// branch's configured upstream.
+func (goGitVCS) upstream(r *gogit.Repository) (string, plumbing.Hash, error) {
+	name := plumbing.ReferenceName("")
+	if ref, err := r.Reference(originHead, false); err == nil {
+		name = ref.Target()
+	} else if head, err := r.Head(); err == nil && head.Name().IsBranch() {
+		cfg, err := r.Config()
+		if err != nil {
+			return "", plumbing.ZeroHash, err
+		}
+		if b := cfg.Branches[head.Name().Short()]; b != nil && b.Remote == "origin" && b.Merge.IsBranch() {
+			name = plumbing.NewRemoteReferenceName("origin", b.Merge.Short())
+		}
+	}
+	if name == "" {
+		return "", plumbing.ZeroHash, fmt.Errorf("cannot tell which branch to pull: origin/HEAD is not set")
+	}
+	ref, err := r.Reference(name, true)
+	if err != nil {
+		return "", plumbing.ZeroHash, fmt.Errorf("%s: %v", name.Short(), err)
+	}
+	return name.Short(), ref.Hash(), nil
+}
+
+// reachable is every commit in from's history. A shallow clone's history
+// simply stops where its commits do.
+func reachable(r *gogit.Repository, from plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	seen := map[plumbing.Hash]bool{}
+	queue := []plumbing.Hash{from}
+	for len(queue) > 0 {
+		h := queue[0]
+		queue = queue[1:]
+		if seen[h] {
+			continue
+		}
+		c, err := r.CommitObject(h)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		seen[h] = true
+		queue = append(queue, c.ParentHashes...)
+	}
+	return seen, nil
+}
+
+func (g goGitVCS) Divergence(dir string) (divergence, error) {
+	var d divergence
+	r, err := g.open(dir)
+	if err != nil {
+		return d, err
+	}
+	var upstream plumbing.Hash
+	if d.Upstream, upstream, err = g.upstream(r); err != nil {
+		return d, err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return d, err
+	}
+	local, err := reachable(r, head.Hash())
+	if err != nil {
+		return d, err
+	}
+	remote, err := reachable(r, upstream)
+	if err != nil {
+		return d, err
+	}
+	for h := range local {
+		if !remote[h] {
+			d.Ahead++
+		}
+	}
+	for h := range remote {
+		if !local[h] {
+			d.Behind++
+		}
+	}
+	return d, nil
+}
+
+func (g goGitVCS) FastForward(dir, ref string) error {
+	r, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	if !head.Name().IsBranch() {
+		return fmt.Errorf("cannot fast-forward a detached HEAD")
+	}
+	target, err := r.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return fmt.Errorf("%s: %v", ref, err)
+	}
+	if *target == head.Hash() {
+		return nil
+	}
+	history, err := reachable(r, *target)
+	if err != nil {
+		return err
+	}
+	if !history[head.Hash()] {
+		return fmt.Errorf("cannot fast-forward %s to %s: the branches have diverged", head.Name().Short(), ref)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	// A merge reset moves the branch and updates the files, refusing if
+	// there are uncommitted changes.
+	return wt.Reset(&gogit.ResetOptions{Commit: *target, Mode: gogit.MergeReset})
+}
+
+func (g goGitVCS) Reset(dir, ref string) error {
+	r, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	target, err := r.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return fmt.Errorf("%s: %v", ref, err)
+	}
+	branch := plumbing.NewBranchReferenceName(strings.TrimPrefix(ref, "origin/"))
+	if err := r.Storer.SetReference(plumbing.NewHashReference(branch, *target)); err != nil {
+		return err
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := wt.Checkout(&gogit.CheckoutOptions{Branch: branch, Force: true}); err != nil {
+		return err
+	}
+	return wt.Clean(&gogit.CleanOptions{Dir: true})
+}
+
+func (g goGitVCS) Discard(dir string) error {
+	r, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return err
+	}
+	var files []string
+	for path, fs := range status {
+		switch {
+		case fs.Worktree == gogit.Untracked:
+		case fs.Staging != gogit.Unmodified:
+			// go-git can only restore files from HEAD, which would also
+			// throw away what is staged.
+			return errVCSUnsupported
+		case fs.Worktree != gogit.Unmodified:
+			files = append(files, path)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	return wt.Restore(&gogit.RestoreOptions{Staged: true, Worktree: true, Files: files})
+}
+
+func (g goGitVCS) SetRemoteURL(dir, url string) error {
+	r, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	origin, ok := cfg.Remotes["origin"]
+	if !ok {
+		origin = &config.RemoteConfig{Name: "origin", Fetch: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"}}
+		cfg.Remotes["origin"] = origin
+	}
+	origin.URLs = []string{url}
+	return r.SetConfig(cfg)
+}
+
+func (g goGitVCS) Configure(dir string, o cloneOptions) error {
+	if o.Filter != "" || len(o.Sparse) > 0 {
+		return errVCSUnsupported
+	}
+	r, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	upstream, _, err := g.upstream(r)
+	if err != nil {
+		return err
+	}
+	refspec := config.RefSpec("+refs/heads/*:refs/remotes/origin/*")
+	if o.SingleBranch {
+		branch := strings.TrimPrefix(upstream, "origin/")
+		refspec = config.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", branch, branch))
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	origin, ok := cfg.Remotes["origin"]
+	if !ok || (len(origin.Fetch) == 1 && origin.Fetch[0] == refspec) {
+		return nil
+	}
+	origin.Fetch = []config.RefSpec{refspec}
+	return r.SetConfig(cfg)
+}
+
+func (g goGitVCS) Log(dir, path string, max int) ([]commitInfo, error) {
+	r, err := g.open(dir)
+	if err != nil {
+		return nil, err
+	}
+	head, err := r.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil // no commits yet
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The default (depth-first) order returns each commit before reading
+	// its parents, so a shallow clone's last commit is still listed.
+	opts := &gogit.LogOptions{From: head.Hash()}
+	if path != "" {
+		// Like git log -- path, a folder matches the files inside it.
+		path = strings.Trim(filepath.ToSlash(path), "/")
+		opts.PathFilter = func(p string) bool { return p == path || strings.HasPrefix(p, path+"/") }
+	}
+	iter, err := r.Log(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var commits []commitInfo
+	err = iter.ForEach(func(c *object.Commit) error {
+		if len(commits) == max {
+			return storer.ErrStop
+		}
+		subject, _, _ := strings.Cut(c.Message, "\n")
+		commits = append(commits, commitInfo{
+			SHA: c.Hash.String(), Author: c.Author.Name, Authored: c.Author.When, Committed: c.Committer.When, Subject: subject,
+		})
+		return nil
+	})
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		err = nil // the end of a shallow clone's history
+	}
+	return commits, err
+}
+
+func (g goGitVCS) RemoteURL(dir string) (string, error) {
+	r, err := g.open(dir)
+	if err != nil {
+		return "", err
+	}
+	return remoteURL(r), nil
+}
+
+func (g goGitVCS) Resolve(dir, rev string) (string, error) {
+	r, err := g.open(dir)
+	if err != nil {
+		return "", err
+	}
+	h, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", fmt.Errorf("%s not found in %s", rev, dir)
+	}
+	return h.String(), nil
+}
+
+func (g goGitVCS) IsAncestor(dir, ancestor, rev string) (bool, error) {
+	r, err := g.open(dir)
+	if err != nil {
+		return false, err
+	}
+	a, err := r.ResolveRevision(plumbing.Revision(ancestor))
+	if err != nil {
+		return false, fmt.Errorf("%s not found in %s", ancestor, dir)
+	}
+	b, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return false, fmt.Errorf("%s not found in %s", rev, dir)
+	}
+	history, err := reachable(r, *b)
+	if err != nil {
+		return false, err
+	}
+	return history[*a], nil
+}
+
+// commitQueue holds commits waiting to be listed, newest commit date
+// first and, for equal dates, in the order they were reached.
+type commitQueue struct {
+	commits []*object.Commit
+	order   []int
+	next    int
+}
+
+func (q *commitQueue) Len() int { return len(q.commits) }
+func (q *commitQueue) Less(i, j int) bool {
+	a, b := q.commits[i].Committer.When, q.commits[j].Committer.When
+	if !a.Equal(b) {
+		return a.After(b)
+	}
+	return q.order[i] < q.order[j]
+}
+func (q *commitQueue) Swap(i, j int) {
+	q.commits[i], q.commits[j] = q.commits[j], q.commits[i]
+	q.order[i], q.order[j] = q.order[j], q.order[i]
+}
+func (q *commitQueue) Push(x any) {
+	q.commits = append(q.commits, x.(*object.Commit))
+	q.order = append(q.order, q.next)
+	q.next++
+}
+func (q *commitQueue) Pop() any {
+	n := len(q.commits) - 1
+	c := q.commits[n]
+	q.commits, q.order = q.commits[:n], q.order[:n]
+	return c
+}
+
+// Commits lists commits in the order git log does, so that commits made in
+// the same second still come after their children.
+func (g goGitVCS) Commits(dir, rev, exclude string) ([]commitInfo, error) {
+	r, err := g.open(dir)
+	if err != nil {
+		return nil, err
+	}
+	from, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("%s not found in %s", rev, dir)
+	}
+	excluded := map[plumbing.Hash]bool{}
+	if exclude != "" {
+		h, err := r.ResolveRevision(plumbing.Revision(exclude))
+		if err != nil {
+			return nil, fmt.Errorf("%s not found in %s", exclude, dir)
+		}
+		if excluded, err = reachable(r, *h); err != nil {
+			return nil, err
+		}
+	}
+
+	var commits []commitInfo
+	queue := &commitQueue{}
+	seen := map[plumbing.Hash]bool{}
+	push := func(h plumbing.Hash) error {
+		if seen[h] || excluded[h] {
+			return nil
+		}
+		seen[h] = true
+		c, err := r.CommitObject(h)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil // the end of a shallow clone's history
+		}
+		if err != nil {
+			return err
+		}
+		heap.Push(queue, c)
+		return nil
+	}
+	if err := push(*from); err != nil {
+		return nil, err
+	}
+	for queue.Len() > 0 {
+		c := heap.Pop(queue).(*object.Commit)
+		subject, _, _ := strings.Cut(c.Message, "\n")
+		commits = append(commits, commitInfo{
+			SHA: c.Hash.String(), Author: c.Author.Name, Authored: c.Author.When, Committed: c.Committer.When, Subject: subject,
+		})
+		for _, p := range c.ParentHashes {
+			if err := push(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return commits, nil
+}
+
+// treeEntryHash is the object path points at in commit rev, or the zero
+// hash when it doesn't exist there.
+func treeEntryHash(r *gogit.Repository, rev, path string) (plumbing.Hash, error) {
+	h, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("%s: %v", rev, err)
+	}
+	c, err := r.CommitObject(*h)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	if path == "" {
+		return tree.Hash, nil
+	}
+	entry, err := tree.FindEntry(path)
+	if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return entry.Hash, nil
+}
+
+func (g goGitVCS) Changed(dir, path, from, to string) (bool, error) {
+	r, err := g.open(dir)
+	if err != nil {
+		return false, err
+	}
+	a, err := treeEntryHash(r, from, path)
+	if err != nil {
+		return false, err
+	}
+	b, err := treeEntryHash(r, to, path)
+	if err != nil {
+		return false, err
+	}
+	return a != b, nil
+}
+
+func (g goGitVCS) Tag(dir, name, sha string) error {
+	r, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	ref := plumbing.NewTagReferenceName(name)
+	if sha == "" {
+		if err := r.Storer.RemoveReference(ref); err != nil && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return err
+		}
+		return nil
+	}
+	h, err := r.ResolveRevision(plumbing.Revision(sha))
+	if err != nil {
+		return fmt.Errorf("%s not found in %s", sha, dir)
+	}
+	return r.Storer.SetReference(plumbing.NewHashReference(ref, *h))
+}
+
+func (g goGitVCS) FetchRefs(dir, url string, refspecs ...string) error {
+	if !goGitCanReach(url) {
+		return errVCSUnsupported
+	}
+	r, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	specs := make([]config.RefSpec, len(refspecs))
+	for i, spec := range refspecs {
+		specs[i] = config.RefSpec(spec)
+	}
+	remote := gogit.NewRemote(r.Storer, &config.RemoteConfig{Name: "anonymous", URLs: []string{url}})
+	err = remote.Fetch(&gogit.FetchOptions{RefSpecs: specs, Tags: gogit.NoTags, Auth: goGitAuth(url)})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return goGitTransportError("fetch", url, err)
+	}
+	return nil
+}
+
+func (g goGitVCS) Shallow(dir string) (bool, time.Time, error) {
+	r, err := g.open(dir)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	commits, err := r.Storer.Shallow()
+	if err != nil || len(commits) == 0 {
+		return false, time.Time{}, err
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return true, time.Time{}, err
+	}
+	section, key, _ := strings.Cut(shallowSinceKey, ".")
+	since, _ := time.Parse(time.RFC3339, cfg.Raw.Section(section).Option(key))
+	return true, since, nil
+}
+
+// Deepen needs git: go-git can't fetch history back to a date or unshallow
+// a clone.
+func (goGitVCS) Deepen(dir string, since time.Time) error {
+	return errVCSUnsupported
+}
+
+// Stash needs git: go-git has no stashes.
+func (goGitVCS) Stash(dir, message string) error {
+	return errVCSUnsupported
+}
+
+// Stashes can only say there are none: counting them means reading the
+// stash reflog, which go-git doesn't.
+func (g goGitVCS) Stashes(dir string) (int, error) {
+	r, err := g.open(dir)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.Reference("refs/stash", false); errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return 0, nil
+	}
+	return 0, errVCSUnsupported
+}
@@ -0,0 +1,654 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// backends are the implementations every test runs against.
+var backends = []struct {
+	name string
+	vcs  vcsBackend
+}{
+	{"git", execVCS{}},
+	{"go-git", goGitVCS{}},
+}
+
+// testRepo is a bare repository standing in for a student's GitHub repo,
+// with a work clone used to push to it.
+type testRepo struct {
+	t      *testing.T
+	bare   string
+	work   string
+	url    string
+	clock  time.Time
+	commit map[string]string // message -> SHA
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newTestRepo creates a bare repository whose main branch has three
+// commits, c1 to c3, and which has a second branch, other.
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	for _, key := range []string{"AUTHOR", "COMMITTER"} {
+		t.Setenv("GIT_"+key+"_NAME", "Student")
+		t.Setenv("GIT_"+key+"_EMAIL", "student@example.com")
+	}
+
+	root := t.TempDir()
+	r := &testRepo{
+		t:      t,
+		bare:   filepath.Join(root, "origin.git"),
+		work:   filepath.Join(root, "work"),
+		clock:  time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		commit: map[string]string{},
+	}
+	// file:// so that --depth is honoured for a local repository.
+	r.url = "file://" + filepath.ToSlash(r.bare)
+	runGit(t, root, "init", "--quiet", "--bare", "-b", "main", r.bare)
+	runGit(t, root, "clone", "--quiet", r.url, r.work)
+
+	r.push("c1", map[string]string{"a.txt": "one\n"})
+	r.push("c2", map[string]string{"b/c.txt": "two\n"})
+	runGit(t, r.work, "checkout", "--quiet", "-b", "other")
+	r.push("o1", map[string]string{"other.txt": "other\n"})
+	runGit(t, r.work, "checkout", "--quiet", "main")
+	r.push("c3", map[string]string{"a.txt": "three\n"})
+	return r
+}
+
+// commitIn commits files in dir, a minute after the previous commit so
+// that history order doesn't depend on timing.
+func (r *testRepo) commitIn(dir, message string, files map[string]string) string {
+	r.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.clock = r.clock.Add(time.Minute)
+	date := r.clock.Format(time.RFC3339)
+	r.t.Setenv("GIT_AUTHOR_DATE", date)
+	r.t.Setenv("GIT_COMMITTER_DATE", date)
+	runGit(r.t, dir, "add", "--all")
+	runGit(r.t, dir, "commit", "--quiet", "-m", message)
+	sha := runGit(r.t, dir, "rev-parse", "HEAD")
+	r.commit[message] = sha
+	return sha
+}
+
+// push commits on the work clone's current branch and pushes it.
+func (r *testRepo) push(message string, files map[string]string) string {
+	r.t.Helper()
+	sha := r.commitIn(r.work, message, files)
+	runGit(r.t, r.work, "push", "--quiet", "origin", "HEAD")
+	return sha
+}
+
+// clone clones the repository with v into a new directory.
+func (r *testRepo) clone(v vcsBackend, o cloneOptions) string {
+	r.t.Helper()
+	dir := filepath.Join(r.t.TempDir(), "student")
+	if err := v.Clone(r.url, dir, o); err != nil {
+		r.t.Fatalf("Clone: %v", err)
+	}
+	return dir
+}
+
+func TestVCSClone(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     cloneOptions
+		wantCommits int
+		wantOther   bool
+	}{
+		{"full", cloneOptions{}, 3, true},
+		{"depth", cloneOptions{Depth: 1}, 1, true},
+		{"single branch", cloneOptions{SingleBranch: true}, 3, false},
+		{"depth and single branch", cloneOptions{Depth: 2, SingleBranch: true}, 2, false},
+	}
+	for _, b := range backends {
+		for _, tt := range tests {
+			t.Run(b.name+"/"+tt.name, func(t *testing.T) {
+				r := newTestRepo(t)
+				dir := r.clone(b.vcs, tt.options)
+
+				commits, err := b.vcs.Log(dir, "", 10)
+				if err != nil {
+					t.Fatalf("Log: %v", err)
+				}
+				if len(commits) != tt.wantCommits {
+					t.Errorf("got %d commits, want %d", len(commits), tt.wantCommits)
+				}
+				if head, err := b.vcs.Head(dir); err != nil || head != r.commit["c3"] {
+					t.Errorf("Head = %q, %v; want c3 %s", head, err, r.commit["c3"])
+				}
+				refs := runGit(t, dir, "for-each-ref", "--format=%(refname)", "refs/remotes/origin/")
+				if got := strings.Contains(refs, "refs/remotes/origin/other"); got != tt.wantOther {
+					t.Errorf("origin/other fetched = %v, want %v (refs: %s)", got, tt.wantOther, refs)
+				}
+				if got := runGit(t, dir, "symbolic-ref", "refs/remotes/origin/HEAD"); got != "refs/remotes/origin/main" {
+					t.Errorf("origin/HEAD = %s, want refs/remotes/origin/main", got)
+				}
+				if url, err := b.vcs.RemoteURL(dir); err != nil || url != r.url {
+					t.Errorf("RemoteURL = %q, %v; want %s", url, err, r.url)
+				}
+				shallow, _, err := b.vcs.Shallow(dir)
+				if err != nil || shallow != (tt.options.Depth > 0) {
+					t.Errorf("Shallow = %v, %v; want %v", shallow, err, tt.options.Depth > 0)
+				}
+			})
+		}
+	}
+}
+
+func TestVCSFetch(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			dir := r.clone(b.vcs, cloneOptions{})
+			// A clone made some other way may not have origin/HEAD.
+			runGit(t, dir, "symbolic-ref", "--delete", "refs/remotes/origin/HEAD")
+			c4 := r.push("c4", map[string]string{"d.txt": "four\n"})
+
+			if err := b.vcs.Fetch(dir); err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			if got := runGit(t, dir, "symbolic-ref", "refs/remotes/origin/HEAD"); got != "refs/remotes/origin/main" {
+				t.Errorf("origin/HEAD = %s, want refs/remotes/origin/main", got)
+			}
+			if got := runGit(t, dir, "rev-parse", "origin/main"); got != c4 {
+				t.Errorf("origin/main = %s, want c4 %s", got, c4)
+			}
+		})
+	}
+}
+
+func TestVCSDivergence(t *testing.T) {
+	tests := []struct {
+		name          string
+		local, remote bool // whether to commit there
+		ahead, behind int
+	}{
+		{"up to date", false, false, 0, 0},
+		{"ahead", true, false, 1, 0},
+		{"behind", false, true, 0, 1},
+		{"diverged", true, true, 1, 1},
+	}
+	for _, b := range backends {
+		for _, tt := range tests {
+			t.Run(b.name+"/"+tt.name, func(t *testing.T) {
+				r := newTestRepo(t)
+				dir := r.clone(b.vcs, cloneOptions{})
+				if tt.local {
+					r.commitIn(dir, "local", map[string]string{"local.txt": "mine\n"})
+				}
+				if tt.remote {
+					r.push("c4", map[string]string{"d.txt": "four\n"})
+				}
+				if err := b.vcs.Fetch(dir); err != nil {
+					t.Fatalf("Fetch: %v", err)
+				}
+
+				d, err := b.vcs.Divergence(dir)
+				if err != nil {
+					t.Fatalf("Divergence: %v", err)
+				}
+				want := divergence{Upstream: "origin/main", Ahead: tt.ahead, Behind: tt.behind}
+				if d != want {
+					t.Errorf("Divergence = %+v, want %+v", d, want)
+				}
+			})
+		}
+	}
+}
+
+func TestVCSFastForward(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name+"/behind", func(t *testing.T) {
+			r := newTestRepo(t)
+			dir := r.clone(b.vcs, cloneOptions{})
+			c4 := r.push("c4", map[string]string{"d.txt": "four\n"})
+			if err := b.vcs.Fetch(dir); err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+
+			if err := b.vcs.FastForward(dir, "origin/main"); err != nil {
+				t.Fatalf("FastForward: %v", err)
+			}
+			if head, _ := b.vcs.Head(dir); head != c4 {
+				t.Errorf("HEAD = %s, want c4 %s", head, c4)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "d.txt")); err != nil {
+				t.Errorf("d.txt not checked out: %v", err)
+			}
+		})
+
+		t.Run(b.name+"/diverged", func(t *testing.T) {
+			r := newTestRepo(t)
+			dir := r.clone(b.vcs, cloneOptions{})
+			local := r.commitIn(dir, "local", map[string]string{"local.txt": "mine\n"})
+			r.push("c4", map[string]string{"d.txt": "four\n"})
+			if err := b.vcs.Fetch(dir); err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+
+			if err := b.vcs.FastForward(dir, "origin/main"); err == nil {
+				t.Fatal("FastForward of a diverged branch succeeded")
+			}
+			if head, _ := b.vcs.Head(dir); head != local {
+				t.Errorf("HEAD moved to %s, want the local commit %s", head, local)
+			}
+		})
+	}
+}
+
+func TestVCSReset(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			dir := r.clone(b.vcs, cloneOptions{})
+			r.commitIn(dir, "local", map[string]string{"local.txt": "mine\n"})
+			c4 := r.push("c4", map[string]string{"d.txt": "four\n"})
+			writeFile(t, filepath.Join(dir, "a.txt"), "edited\n")
+			writeFile(t, filepath.Join(dir, "untracked.txt"), "scratch\n")
+			runGit(t, dir, "checkout", "--quiet", "--detach")
+			if err := b.vcs.Fetch(dir); err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+
+			if err := b.vcs.Reset(dir, "origin/main"); err != nil {
+				t.Fatalf("Reset: %v", err)
+			}
+			s, err := b.vcs.Status(dir)
+			if err != nil {
+				t.Fatalf("Status: %v", err)
+			}
+			if s.Branch != "main" || s.Head != c4 || len(s.Dirty) > 0 {
+				t.Errorf("after Reset: branch %q, head %s, dirty %q; want main at c4 %s, clean", s.Branch, s.Head, s.Dirty, c4)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "untracked.txt")); !os.IsNotExist(err) {
+				t.Errorf("untracked file survived Reset: %v", err)
+			}
+		})
+	}
+}
+
+func TestVCSDiscard(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			dir := r.clone(b.vcs, cloneOptions{})
+			writeFile(t, filepath.Join(dir, "a.txt"), "edited\n")
+			writeFile(t, filepath.Join(dir, "untracked.txt"), "scratch\n")
+
+			if err := b.vcs.Discard(dir); err != nil {
+				t.Fatalf("Discard: %v", err)
+			}
+			if got := readFile(t, filepath.Join(dir, "a.txt")); got != "three\n" {
+				t.Errorf("a.txt = %q after Discard, want %q", got, "three\n")
+			}
+			if got := readFile(t, filepath.Join(dir, "untracked.txt")); got != "scratch\n" {
+				t.Errorf("untracked.txt = %q after Discard, want it kept", got)
+			}
+		})
+	}
+}
+
+func TestVCSLog(t *testing.T) {
+	tests := []struct {
+		path string
+		max  int
+		want []string
+	}{
+		{"", 10, []string{"c3", "c2", "c1"}},
+		{"", 2, []string{"c3", "c2"}},
+		{"a.txt", 10, []string{"c3", "c1"}},
+		{"b", 10, []string{"c2"}},
+		{"other.txt", 10, nil},
+	}
+	for _, b := range backends {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s/%s/%d", b.name, tt.path, tt.max), func(t *testing.T) {
+				r := newTestRepo(t)
+				dir := r.clone(b.vcs, cloneOptions{})
+
+				commits, err := b.vcs.Log(dir, tt.path, tt.max)
+				if err != nil {
+					t.Fatalf("Log: %v", err)
+				}
+				var got []string
+				for _, c := range commits {
+					got = append(got, c.Subject)
+					if c.SHA != r.commit[c.Subject] {
+						t.Errorf("%s: SHA %s, want %s", c.Subject, c.SHA, r.commit[c.Subject])
+					}
+					if c.Author != "Student" || c.Authored.IsZero() || c.Committed.IsZero() {
+						t.Errorf("%s: author %q, dates %v and %v", c.Subject, c.Author, c.Authored, c.Committed)
+					}
+				}
+				if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+					t.Errorf("Log(%q, %d) = %v, want %v", tt.path, tt.max, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestVCSResolve(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			dir := r.clone(b.vcs, cloneOptions{})
+
+			for rev, want := range map[string]string{
+				"HEAD":                     r.commit["c3"],
+				"origin/other":             r.commit["o1"],
+				"refs/remotes/origin/HEAD": r.commit["c3"],
+				r.commit["c2"]:             r.commit["c2"],
+			} {
+				if got, err := b.vcs.Resolve(dir, rev); err != nil || got != want {
+					t.Errorf("Resolve(%s) = %q, %v; want %s", rev, got, err, want)
+				}
+			}
+			if _, err := b.vcs.Resolve(dir, strings.Repeat("1", 40)); err == nil {
+				t.Error("Resolve of a missing commit succeeded")
+			}
+
+			tests := []struct {
+				ancestor, rev string
+				want          bool
+			}{
+				{r.commit["c1"], r.commit["c3"], true},
+				{r.commit["c3"], r.commit["c3"], true},
+				{r.commit["c3"], r.commit["c1"], false},
+				{r.commit["o1"], "HEAD", false},
+			}
+			for _, tt := range tests {
+				if got, err := b.vcs.IsAncestor(dir, tt.ancestor, tt.rev); err != nil || got != tt.want {
+					t.Errorf("IsAncestor(%.7s, %.7s) = %v, %v; want %v", tt.ancestor, tt.rev, got, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVCSCommits(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			dir := r.clone(b.vcs, cloneOptions{})
+
+			tests := []struct {
+				rev, exclude string
+				want         []string
+			}{
+				{"HEAD", "", []string{"c3", "c2", "c1"}},
+				{"HEAD", r.commit["c1"], []string{"c3", "c2"}},
+				{"origin/other", "HEAD", []string{"o1"}},
+				{"HEAD", "HEAD", nil},
+			}
+			for _, tt := range tests {
+				commits, err := b.vcs.Commits(dir, tt.rev, tt.exclude)
+				if err != nil {
+					t.Fatalf("Commits(%s, %.7s): %v", tt.rev, tt.exclude, err)
+				}
+				var got []string
+				for _, c := range commits {
+					got = append(got, c.Subject)
+				}
+				if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+					t.Errorf("Commits(%s, %.7s) = %v, want %v", tt.rev, tt.exclude, got, tt.want)
+				}
+			}
+
+			// Commits made in the same second still list children first.
+			for _, message := range []string{"s1", "s2", "s3"} {
+				runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", message)
+			}
+			commits, err := b.vcs.Commits(dir, "HEAD", r.commit["c3"])
+			if err != nil {
+				t.Fatalf("Commits: %v", err)
+			}
+			var got []string
+			for _, c := range commits {
+				got = append(got, c.Subject)
+			}
+			if strings.Join(got, " ") != "s3 s2 s1" {
+				t.Errorf("same-second commits listed as %v, want [s3 s2 s1]", got)
+			}
+		})
+	}
+}
+
+func TestVCSChanged(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			dir := r.clone(b.vcs, cloneOptions{})
+
+			tests := []struct {
+				path, from string
+				want       bool
+			}{
+				{"a.txt", "c1", true},
+				{"a.txt", "c2", true},
+				{"b/c.txt", "c1", true}, // added
+				{"b", "c2", false},
+				{"missing.txt", "c1", false},
+			}
+			for _, tt := range tests {
+				got, err := b.vcs.Changed(dir, tt.path, r.commit[tt.from], "HEAD")
+				if err != nil || got != tt.want {
+					t.Errorf("Changed(%s, %s..HEAD) = %v, %v; want %v", tt.path, tt.from, got, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVCSTag(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			dir := r.clone(b.vcs, cloneOptions{})
+			const tag = "deadline/1-portfolio"
+
+			if err := b.vcs.Tag(dir, tag, r.commit["c2"]); err != nil {
+				t.Fatalf("Tag: %v", err)
+			}
+			if got, err := b.vcs.Resolve(dir, tag); err != nil || got != r.commit["c2"] {
+				t.Errorf("tag at %q, %v; want c2 %s", got, err, r.commit["c2"])
+			}
+			if err := b.vcs.Tag(dir, tag, r.commit["c3"]); err != nil {
+				t.Fatalf("moving the tag: %v", err)
+			}
+			if got, _ := b.vcs.Resolve(dir, tag); got != r.commit["c3"] {
+				t.Errorf("moved tag at %q, want c3 %s", got, r.commit["c3"])
+			}
+			for range 2 {
+				if err := b.vcs.Tag(dir, tag, ""); err != nil {
+					t.Fatalf("deleting the tag: %v", err)
+				}
+			}
+			if _, err := b.vcs.Resolve(dir, tag); err == nil {
+				t.Error("tag still resolves after deleting it")
+			}
+		})
+	}
+}
+
+func TestVCSFetchRefs(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			old := r.clone(b.vcs, cloneOptions{})
+			runGit(t, old, "tag", "deadline/1-portfolio", r.commit["c2"])
+			runGit(t, old, "tag", "v1", r.commit["c1"])
+			dir := r.clone(b.vcs, cloneOptions{})
+
+			if err := b.vcs.FetchRefs(dir, old, "refs/tags/deadline/*:refs/tags/deadline/*"); err != nil {
+				t.Fatalf("FetchRefs: %v", err)
+			}
+			if got, err := b.vcs.Resolve(dir, "deadline/1-portfolio"); err != nil || got != r.commit["c2"] {
+				t.Errorf("deadline tag at %q, %v; want c2 %s", got, err, r.commit["c2"])
+			}
+			if _, err := b.vcs.Resolve(dir, "v1"); err == nil {
+				t.Error("FetchRefs fetched a tag outside its refspecs")
+			}
+			// Nothing matching is not an error.
+			if err := b.vcs.FetchRefs(dir, old, "refs/tags/none/*:refs/tags/none/*"); err != nil {
+				t.Errorf("FetchRefs with nothing to fetch: %v", err)
+			}
+		})
+	}
+}
+
+func TestVCSDeepen(t *testing.T) {
+	r := newTestRepo(t)
+	dir := r.clone(execVCS{}, cloneOptions{Depth: 1})
+	if err := (goGitVCS{}).Deepen(dir, time.Time{}); !errors.Is(err, errVCSUnsupported) {
+		t.Errorf("go-git Deepen = %v, want errVCSUnsupported", err)
+	}
+
+	// c2 and c3 are the commits after c1.
+	c1, err := execVCS{}.Commits(r.work, r.commit["c1"], "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := c1[0].Committed.Add(time.Second)
+	if err := (execVCS{}).Deepen(dir, since); err != nil {
+		t.Fatalf("Deepen: %v", err)
+	}
+	for _, b := range backends {
+		shallow, deepened, err := b.vcs.Shallow(dir)
+		if err != nil || !shallow || !deepened.Equal(since) {
+			t.Errorf("%s: Shallow = %v, %v, %v; want shallow since %v", b.name, shallow, deepened, err, since)
+		}
+	}
+	if commits, _ := (execVCS{}).Commits(dir, "HEAD", ""); len(commits) != 2 {
+		t.Errorf("deepened clone has %d commits, want c3 and c2", len(commits))
+	}
+
+	if err := (execVCS{}).Deepen(dir, time.Time{}); err != nil {
+		t.Fatalf("Deepen to the whole history: %v", err)
+	}
+	if shallow, _, _ := (execVCS{}).Shallow(dir); shallow {
+		t.Error("clone still shallow after deepening to the whole history")
+	}
+}
+
+func TestVCSStash(t *testing.T) {
+	r := newTestRepo(t)
+	dir := r.clone(execVCS{}, cloneOptions{})
+	if n, err := (goGitVCS{}).Stashes(dir); err != nil || n != 0 {
+		t.Errorf("go-git Stashes without a stash = %d, %v; want 0", n, err)
+	}
+	if err := (goGitVCS{}).Stash(dir, "scv"); !errors.Is(err, errVCSUnsupported) {
+		t.Errorf("go-git Stash = %v, want errVCSUnsupported", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "a.txt"), "edited\n")
+	writeFile(t, filepath.Join(dir, "untracked.txt"), "scratch\n")
+	if err := (execVCS{}).Stash(dir, "scv: before pull"); err != nil {
+		t.Fatalf("Stash: %v", err)
+	}
+	if s, _ := (execVCS{}).Status(dir); len(s.Dirty) > 0 {
+		t.Errorf("dirty after Stash: %q", s.Dirty)
+	}
+	if n, err := (execVCS{}).Stashes(dir); err != nil || n != 1 {
+		t.Errorf("Stashes = %d, %v; want 1", n, err)
+	}
+	// go-git can't count stashes, so the fallback asks git.
+	if _, err := (goGitVCS{}).Stashes(dir); !errors.Is(err, errVCSUnsupported) {
+		t.Errorf("go-git Stashes with a stash = %v, want errVCSUnsupported", err)
+	}
+	v := fallbackVCS{primary: goGitVCS{}, fallback: execVCS{}}
+	if n, err := v.Stashes(dir); err != nil || n != 1 {
+		t.Errorf("fallback Stashes = %d, %v; want 1", n, err)
+	}
+}
+
+func TestFallbackVCS(t *testing.T) {
+	r := newTestRepo(t)
+	v := fallbackVCS{primary: goGitVCS{}, fallback: execVCS{}}
+	// go-git can't make sparse clones, so git does.
+	dir := r.clone(v, cloneOptions{Sparse: []string{"b"}})
+	if _, err := os.Stat(filepath.Join(dir, "b", "c.txt")); err != nil {
+		t.Errorf("b/c.txt not checked out: %v", err)
+	}
+	if got := runGit(t, dir, "config", "--get", "core.sparseCheckout"); got != "true" {
+		t.Errorf("core.sparseCheckout = %q, want true", got)
+	}
+	if _, err := (goGitVCS{}).Status(dir); !errors.Is(err, errVCSUnsupported) {
+		t.Errorf("go-git opened a sparse clone: %v", err)
+	}
+	if s, err := v.Status(dir); err != nil || s.Head != r.commit["c3"] {
+		t.Errorf("Status = %+v, %v; want HEAD at c3", s, err)
+	}
+}
+
+func TestGoGitCanReach(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	writeFile(t, filepath.Join(home, ".gitconfig"), "[url \"git@example.com:\"]\n\tinsteadOf = https://example.com/\n")
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://github.com/student/student.github.io", true},
+		{"file:///srv/git/student.git", true},
+		{"/srv/git/student.git", true},
+		{"git@github.com:student/student.github.io.git", false},
+		{"ssh://git@github.com/student/student.github.io.git", false},
+		{"https://example.com/student/student.github.io", false}, // rewritten by insteadOf
+	}
+	for _, tt := range tests {
+		if got := goGitCanReach(tt.url); got != tt.want {
+			t.Errorf("goGitCanReach(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}